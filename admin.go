@@ -0,0 +1,252 @@
+package fakehttp
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// AdminPrefix is the URL path prefix reserved for the admin API of
+// AdminHandler.
+const AdminPrefix = "/__admin"
+
+// AdminHandler is an HTTP handler that serves a MultipleHandler together with
+// an admin API under AdminPrefix, so that a fake running as a separate
+// process can be configured over HTTP.
+//
+// The admin API consists of the following endpoints:
+//
+//	GET    /__admin/stubs               list the stubs
+//	POST   /__admin/stubs               create a stub from a Stub JSON
+//	DELETE /__admin/stubs               delete all the stubs
+//	DELETE /__admin/stubs/{id}          delete the stub
+//	POST   /__admin/reset               restore the initial stubs, clear the journal and reset the scenarios
+//	GET    /__admin/requests            list the journal entries
+//	DELETE /__admin/requests            clear the journal
+//	GET    /__admin/requests/unmatched  list the unmatched journal entries
+//	GET    /__admin/requests/har        export the journal in the HAR format
+//	GET    /__admin/scenarios           list the scenario states
+//	POST   /__admin/scenarios/reset     reset the scenarios to ScenarioStarted
+//
+// Stubs created with the admin API take precedence over existing ones.
+type AdminHandler struct {
+	mu      sync.RWMutex
+	handler *MultipleHandler
+	initial []adminStub
+	stubs   []adminStub
+	nextID  int
+}
+
+type adminStub struct {
+	ID         string `json:"id"`
+	Method     string `json:"method"`
+	PathFmt    string `json:"path,omitempty"`
+	PathRegexp string `json:"pathRegexp,omitempty"`
+	Stub       *Stub  `json:"stub,omitempty"`
+
+	handler JSONHandler
+}
+
+type adminError struct {
+	Message string
+}
+
+// NewAdminHandler creates an instance of AdminHandler serving h.
+// If h.Journal or h.Scenarios is nil, a new one is set.
+// h must not be modified directly while the AdminHandler is serving.
+func NewAdminHandler(h *MultipleHandler) *AdminHandler {
+	if h.Journal == nil {
+		h.Journal = &Journal{}
+	}
+	if h.Scenarios == nil {
+		h.Scenarios = &Scenarios{}
+	}
+	a := &AdminHandler{handler: h}
+	for _, handler := range h.handlers {
+		a.initial = append(a.initial, a.newStub(handler, nil))
+	}
+	a.stubs = append([]adminStub{}, a.initial...)
+	return a
+}
+
+func (a *AdminHandler) newStub(h JSONHandler, s *Stub) adminStub {
+	a.nextID++
+	ret := adminStub{
+		ID:      strconv.Itoa(a.nextID),
+		Method:  h.Method,
+		PathFmt: h.PathFmt,
+		Stub:    s,
+		handler: h,
+	}
+	if h.PathRegexp != nil {
+		ret.PathRegexp = h.PathRegexp.String()
+	}
+	return ret
+}
+
+// AddHandler adds a JSONHandler which takes precedence over the existing ones
+// and returns its ID.
+func (a *AdminHandler) AddHandler(h JSONHandler) (string, error) {
+	return a.add(h, nil)
+}
+
+func (a *AdminHandler) add(h JSONHandler, s *Stub) (string, error) {
+	if !h.routable() {
+		return "", errors.New("method and path must not be empty")
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stub := a.newStub(h, s)
+	a.stubs = append([]adminStub{stub}, a.stubs...)
+	a.update()
+	return stub.ID, nil
+}
+
+// RemoveHandler removes the JSONHandler with the ID and reports whether it
+// existed.
+func (a *AdminHandler) RemoveHandler(id string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stubs := make([]adminStub, 0, len(a.stubs))
+	for _, s := range a.stubs {
+		if s.ID != id {
+			stubs = append(stubs, s)
+		}
+	}
+	found := len(stubs) != len(a.stubs)
+	a.stubs = stubs
+	a.update()
+	return found
+}
+
+// Reset restores the JSONHandlers given at creation, clears the journal and
+// resets the scenarios.
+func (a *AdminHandler) Reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.stubs = append([]adminStub{}, a.initial...)
+	a.update()
+	a.handler.Journal.Reset()
+	a.handler.Scenarios.Reset()
+}
+
+// ReplaceInitial replaces the JSONHandlers given at creation with those of h,
+// e.g. when the stub files are reloaded.  The JSONHandlers added later, the
+// journal and the scenario states are kept, since the Scenarios of the new
+// JSONHandlers are replaced with the ones of the same names.
+func (a *AdminHandler) ReplaceInitial(h *MultipleHandler) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	initialIDs := map[string]bool{}
+	for _, s := range a.initial {
+		initialIDs[s.ID] = true
+	}
+	stubs := []adminStub{}
+	for _, s := range a.stubs {
+		if !initialIDs[s.ID] {
+			stubs = append(stubs, s)
+		}
+	}
+
+	a.initial = nil
+	for _, handler := range h.handlers {
+		if handler.Scenario != nil {
+			handler.Scenario = a.handler.Scenarios.Get(handler.Scenario.Name)
+		}
+		a.initial = append(a.initial, a.newStub(handler, nil))
+	}
+	a.stubs = append(stubs, a.initial...)
+	a.update()
+}
+
+// update rebuilds the handlers of the MultipleHandler.  A new slice is always
+// allocated since copies of the MultipleHandler may be serving requests.
+func (a *AdminHandler) update() {
+	handlers := make([]JSONHandler, 0, len(a.stubs))
+	for _, s := range a.stubs {
+		handlers = append(handlers, s.handler)
+	}
+	a.handler.handlers = handlers
+}
+
+// ServeHTTP is a method to implement http.Handler.
+func (a *AdminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == AdminPrefix || strings.HasPrefix(r.URL.Path, AdminPrefix+"/") {
+		a.serveAdmin(w, r)
+		return
+	}
+
+	a.mu.RLock()
+	h := *a.handler
+	a.mu.RUnlock()
+	h.ServeHTTP(w, r)
+}
+
+func (a *AdminHandler) serveAdmin(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(r.URL.Path, AdminPrefix)
+	switch {
+	case p == "/stubs" && r.Method == http.MethodGet:
+		a.mu.RLock()
+		stubs := append([]adminStub{}, a.stubs...)
+		a.mu.RUnlock()
+		writeAdminJSON(w, http.StatusOK, stubs)
+	case p == "/stubs" && r.Method == http.MethodPost:
+		s := Stub{}
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			writeAdminJSON(w, http.StatusBadRequest, adminError{Message: err.Error()})
+			return
+		}
+		h, err := s.JSONHandler("", a.handler.Scenarios)
+		if err != nil {
+			writeAdminJSON(w, http.StatusBadRequest, adminError{Message: err.Error()})
+			return
+		}
+		id, err := a.add(h, &s)
+		if err != nil {
+			writeAdminJSON(w, http.StatusBadRequest, adminError{Message: err.Error()})
+			return
+		}
+		writeAdminJSON(w, http.StatusCreated, map[string]string{"id": id})
+	case p == "/stubs" && r.Method == http.MethodDelete:
+		a.mu.Lock()
+		a.stubs = []adminStub{}
+		a.update()
+		a.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(p, "/stubs/") && r.Method == http.MethodDelete:
+		if !a.RemoveHandler(strings.TrimPrefix(p, "/stubs/")) {
+			writeAdminJSON(w, http.StatusNotFound, adminError{Message: "stub not found"})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case p == "/reset" && r.Method == http.MethodPost:
+		a.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	case p == "/requests" && r.Method == http.MethodGet:
+		writeAdminJSON(w, http.StatusOK, a.handler.Journal.Entries())
+	case p == "/requests" && r.Method == http.MethodDelete:
+		a.handler.Journal.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	case p == "/requests/unmatched" && r.Method == http.MethodGet:
+		writeAdminJSON(w, http.StatusOK, a.handler.Journal.Unmatched())
+	case p == "/requests/har" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		a.handler.Journal.WriteHAR(w)
+	case p == "/scenarios" && r.Method == http.MethodGet:
+		writeAdminJSON(w, http.StatusOK, a.handler.Scenarios.States())
+	case p == "/scenarios/reset" && r.Method == http.MethodPost:
+		a.handler.Scenarios.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeAdminJSON(w, http.StatusNotFound, adminError{Message: "unknown admin endpoint"})
+	}
+}
+
+func writeAdminJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,174 @@
+package fakehttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	h := NewAdminHandler(NewMultipleHandler([]JSONHandler{
+		{
+			Method:       "GET",
+			PathFmt:      "/users/*",
+			ResponseCode: 200,
+			ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+				return map[string]string{"name": "initial"}, nil
+			},
+		},
+	}))
+
+	do := func(method, target string, body io.Reader) (int, []byte) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "http://localhost"+target, body))
+		return w.Code, w.Body.Bytes()
+	}
+
+	code, body := do("POST", "/__admin/stubs", strings.NewReader(`{
+		"request": {"method": "GET", "path": "/users/1"},
+		"response": {"status": 200, "body": {"name": "created"}}
+	}`))
+	if code != 201 {
+		t.Fatalf("want 201, but got %v: %s", code, body)
+	}
+	created := map[string]string{}
+	json.Unmarshal(body, &created)
+
+	if _, body := do("GET", "/users/1", nil); !strings.Contains(string(body), "created") {
+		t.Fatalf("want created stub to take precedence, but got %s", body)
+	}
+	if _, body := do("GET", "/users/2", nil); !strings.Contains(string(body), "initial") {
+		t.Fatalf("want initial stub, but got %s", body)
+	}
+	do("GET", "/unknown", nil)
+
+	stubs := []adminStub{}
+	_, body = do("GET", "/__admin/stubs", nil)
+	json.Unmarshal(body, &stubs)
+	if len(stubs) != 2 || stubs[0].ID != created["id"] || stubs[0].Stub == nil {
+		t.Fatalf("want created stub at first, but got %s", body)
+	}
+
+	entries := []JournalEntry{}
+	_, body = do("GET", "/__admin/requests", nil)
+	json.Unmarshal(body, &entries)
+	if len(entries) != 3 {
+		t.Fatalf("want 3 entries, but got %s", body)
+	}
+	_, body = do("GET", "/__admin/requests/unmatched", nil)
+	json.Unmarshal(body, &entries)
+	if len(entries) != 1 || entries[0].URL != "/unknown" || entries[0].StatusCode != 404 {
+		t.Fatalf("want an unmatched entry, but got %s", body)
+	}
+
+	if code, _ := do("DELETE", "/__admin/stubs/"+created["id"], nil); code != 204 {
+		t.Fatalf("want 204, but got %v", code)
+	}
+	if code, _ := do("DELETE", "/__admin/stubs/"+created["id"], nil); code != 404 {
+		t.Fatalf("want 404, but got %v", code)
+	}
+	if _, body := do("GET", "/users/1", nil); !strings.Contains(string(body), "initial") {
+		t.Fatalf("want initial stub, but got %s", body)
+	}
+
+	if code, _ := do("DELETE", "/__admin/stubs", nil); code != 204 {
+		t.Fatalf("want 204, but got %v", code)
+	}
+	if code, _ := do("GET", "/users/1", nil); code != 404 {
+		t.Fatalf("want 404, but got %v", code)
+	}
+
+	if code, _ := do("POST", "/__admin/reset", nil); code != 204 {
+		t.Fatalf("want 204, but got %v", code)
+	}
+	if code, _ := do("GET", "/users/1", nil); code != 200 {
+		t.Fatalf("want 200, but got %v", code)
+	}
+	if got := h.handler.Journal.Entries(); len(got) != 1 {
+		t.Fatalf("want 1 entry after reset, but got %v", got)
+	}
+}
+
+func TestAdminHandler_invalidStub(t *testing.T) {
+	cases := []string{
+		`{`,
+		`{"request": {"method": "GET"}}`,
+	}
+	h := NewAdminHandler(NewMultipleHandler(nil))
+	for _, tt := range cases {
+		t.Run(tt, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost/__admin/stubs", strings.NewReader(tt)))
+			if w.Code != 400 {
+				t.Fatalf("want 400, but got %v", w.Code)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ReplaceInitial(t *testing.T) {
+	load := func(name string) *MultipleHandler {
+		scenarios := &Scenarios{}
+		h := NewMultipleHandler([]JSONHandler{
+			{
+				Method:       "GET",
+				PathFmt:      "/users/*",
+				ResponseCode: 200,
+				ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+					return map[string]string{"name": name}, nil
+				},
+			},
+			{
+				Method:       "POST",
+				PathFmt:      "/orders",
+				ResponseCode: 201,
+				Scenario:     scenarios.Get("order"),
+				NewState:     "Ordered",
+			},
+		})
+		h.Scenarios = scenarios
+		return h
+	}
+	h := NewAdminHandler(load("initial"))
+	do := func(method, target string, body io.Reader) []byte {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "http://localhost"+target, body))
+		return w.Body.Bytes()
+	}
+
+	do("POST", "/__admin/stubs", strings.NewReader(`{
+		"request": {"method": "GET", "path": "/users/1"},
+		"response": {"status": 200, "body": {"name": "created"}}
+	}`))
+	do("POST", "/orders", nil)
+
+	h.ReplaceInitial(load("reloaded"))
+
+	if body := do("GET", "/users/1", nil); !strings.Contains(string(body), "created") {
+		t.Fatalf("want created stub to be kept, but got %s", body)
+	}
+	if body := do("GET", "/users/2", nil); !strings.Contains(string(body), "reloaded") {
+		t.Fatalf("want reloaded stub, but got %s", body)
+	}
+	if got := h.handler.Scenarios.States()["order"]; got != "Ordered" {
+		t.Fatalf("want scenario state to be kept, but got %v", got)
+	}
+	if got := len(h.handler.Journal.Entries()); got != 3 {
+		t.Fatalf("want journal to be kept, but got %v entries", got)
+	}
+
+	// The scenario of the reloaded stub is shared with the AdminHandler.
+	h.Reset()
+	do("POST", "/orders", nil)
+	if got := h.handler.Scenarios.States()["order"]; got != "Ordered" {
+		t.Fatalf("want reloaded stub to change the scenario, but got %v", got)
+	}
+	if body := do("GET", "/users/1", nil); !strings.Contains(string(body), "reloaded") {
+		t.Fatalf("want reloaded stubs after reset, but got %s", body)
+	}
+}
@@ -0,0 +1,131 @@
+package fakehttp
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ChaosEvent is a failure injected by Chaos.
+type ChaosEvent string
+
+const (
+	// ChaosError is a 5xx error response.
+	ChaosError ChaosEvent = "error"
+	// ChaosLatency is a delayed response.
+	ChaosLatency ChaosEvent = "latency"
+	// ChaosDrop is a connection closed without responding.
+	ChaosDrop ChaosEvent = "drop"
+)
+
+// Chaos is an HTTP handler wrapping a MultipleHandler, which randomly injects
+// failures into the responses at the configured rates.  The failures are
+// reproducible from the seed as long as the requests are served in the same
+// order.  The injected failures are marked in MultipleHandler.Journal.
+type Chaos struct {
+	// ErrorRate is the probability in [0, 1] to respond with one of
+	// ErrorCodes instead of serving the request.
+	ErrorRate float64
+	// ErrorCodes are the HTTP response codes of the injected errors.
+	// Defaults to 500, 502, 503 and 504.
+	ErrorCodes []int
+	// LatencyRate is the probability in [0, 1] to delay the response by
+	// Latency.
+	LatencyRate float64
+	// Latency is the delay injected into the responses.  Defaults to a
+	// duration uniformly distributed in [0, 1s) generated from the seed.
+	Latency Delay
+	// DropRate is the probability in [0, 1] to close the connection without
+	// responding.
+	DropRate float64
+
+	handler *MultipleHandler
+	seed    int64
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// NewChaos creates an instance of Chaos wrapping h.  If seed is 0, a seed is
+// generated from the current time.  The seed is logged with t unless t is nil,
+// so that a failing run can be replayed by passing the same seed; *testing.T
+// can be specified as t.
+func NewChaos(h *MultipleHandler, seed int64, t interface {
+	Logf(string, ...interface{})
+}) *Chaos {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if t != nil {
+		t.Logf("fakehttp: chaos seed %v", seed)
+	}
+	return &Chaos{
+		ErrorCodes: []int{
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		Latency: UniformDelay(0, time.Second, seed),
+		handler: h,
+		seed:    seed,
+		rnd:     rand.New(rand.NewSource(seed)),
+	}
+}
+
+// Seed returns the seed of the random numbers.
+func (c *Chaos) Seed() int64 {
+	return c.seed
+}
+
+// ServeHTTP is a method to implement http.Handler.
+func (c *Chaos) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := *c.handler
+	event, code := c.next()
+	rt := h.route(r)
+	switch event {
+	case ChaosDrop:
+		h.serveJournaled(w, r, event, rt, func(w http.ResponseWriter, r *http.Request, rt route) bool {
+			abort(w, true)
+			return rt.ok
+		})
+	case ChaosError:
+		h.serveJournaled(w, r, event, rt, func(w http.ResponseWriter, r *http.Request, rt route) bool {
+			h.errorResponse(w, errors.New("chaos: injected error"), code)
+			return rt.ok
+		})
+	case ChaosLatency:
+		h.serveJournaled(w, r, event, rt, func(w http.ResponseWriter, r *http.Request, rt route) bool {
+			if !sleep(r.Context(), h.Clock, c.Latency.Duration()) {
+				return rt.ok
+			}
+			return h.serveHTTP(w, r, rt)
+		})
+	default:
+		h.serveJournaled(w, r, "", rt, h.serveHTTP)
+	}
+}
+
+// next decides the failure to inject into a response.  Every call consumes the
+// same amount of random numbers, so that the following decisions do not
+// depend on the rates.
+func (c *Chaos) next() (ChaosEvent, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	drop, fail, delay, pick := c.rnd.Float64(), c.rnd.Float64(), c.rnd.Float64(), c.rnd.Float64()
+	code := http.StatusInternalServerError
+	if len(c.ErrorCodes) != 0 {
+		code = c.ErrorCodes[int(pick*float64(len(c.ErrorCodes)))]
+	}
+	switch {
+	case drop < c.DropRate:
+		return ChaosDrop, 0
+	case fail < c.ErrorRate:
+		return ChaosError, code
+	case delay < c.LatencyRate:
+		return ChaosLatency, 0
+	}
+	return "", 0
+}
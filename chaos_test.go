@@ -0,0 +1,96 @@
+package fakehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestChaos_ServeHTTP(t *testing.T) {
+	run := func(seed int64) []JournalEntry {
+		h := NewMultipleHandler([]JSONHandler{
+			{Method: "GET", PathFmt: "/users", ResponseCode: 204},
+		})
+		h.Journal = &Journal{}
+		c := NewChaos(h, seed, t)
+		c.ErrorRate = 0.3
+		c.ErrorCodes = []int{503}
+		c.LatencyRate = 0.3
+		c.Latency = FixedDelay(time.Millisecond)
+		c.DropRate = 0.1
+		s := httptest.NewServer(c)
+		defer s.Close()
+
+		// Keep-alive connections make the client retry the dropped requests.
+		client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+		for i := 0; i < 50; i++ {
+			if res, err := client.Get(s.URL + "/users"); err == nil {
+				res.Body.Close()
+			}
+		}
+		// A dropped request is recorded after the connection is closed.
+		for i := 0; i < 100 && len(h.Journal.Entries()) < 50; i++ {
+			time.Sleep(time.Millisecond)
+		}
+		entries := h.Journal.Entries()
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Time.Before(entries[j].Time)
+		})
+		return entries
+	}
+
+	events := func(entries []JournalEntry) []ChaosEvent {
+		ret := []ChaosEvent{}
+		for _, e := range entries {
+			ret = append(ret, e.Chaos)
+		}
+		return ret
+	}
+
+	entries := run(42)
+	if len(entries) != 50 {
+		t.Fatalf("want 50 entries, but got %v", len(entries))
+	}
+	counts := map[ChaosEvent]int{}
+	for _, e := range entries {
+		counts[e.Chaos]++
+		want := map[ChaosEvent]int{"": 204, ChaosLatency: 204, ChaosError: 503, ChaosDrop: 0}[e.Chaos]
+		if e.StatusCode != want {
+			t.Fatalf("%v: want %v, but got %v", e.Chaos, want, e.StatusCode)
+		}
+		if !e.Matched {
+			t.Fatalf("%v: want matched, but not", e.Chaos)
+		}
+	}
+	for _, event := range []ChaosEvent{"", ChaosError, ChaosLatency, ChaosDrop} {
+		if counts[event] == 0 {
+			t.Fatalf("want %q injected, but got %v", event, counts)
+		}
+	}
+
+	if got, want := events(run(42)), events(entries); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want reproducible %v, but got %v", want, got)
+	}
+}
+
+func TestNewChaos_seed(t *testing.T) {
+	ft := &fakeLogger{}
+	c := NewChaos(NewMultipleHandler(nil), 0, ft)
+	if c.Seed() == 0 {
+		t.Fatalf("want a generated seed, but got 0")
+	}
+	if len(ft.logs) != 1 {
+		t.Fatalf("want the seed logged, but got %v", ft.logs)
+	}
+}
+
+type fakeLogger struct {
+	logs []string
+}
+
+func (l *fakeLogger) Logf(format string, args ...interface{}) {
+	l.logs = append(l.logs, format)
+}
@@ -0,0 +1,103 @@
+package fakehttp
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the current time and waits for durations.  It is used by every
+// time-dependent feature such as Latency, RateLimiter, the Date header and the
+// timestamps in Journal, so that they can be tested without waiting with a
+// FakeClock.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel that receives the current time after the
+	// duration.
+	After(d time.Duration) <-chan time.Time
+}
+
+// now returns the current time of c, or of the system clock if c is nil.
+func now(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+// FakeClock is a Clock whose time only changes by calling Advance() or
+// Set().  It is safe for concurrent use.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeClockWaiter
+}
+
+type fakeClockWaiter struct {
+	until time.Time
+	c     chan time.Time
+}
+
+// NewFakeClock creates an instance of FakeClock set to the time.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// After returns a channel that receives the current time when the clock is
+// advanced by the duration.
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.waiters = append(c.waiters, fakeClockWaiter{until: c.now.Add(d), c: ch})
+	return ch
+}
+
+// Advance moves the clock forward by the duration, and wakes up the waiters
+// whose durations have elapsed.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(c.now.Add(d))
+}
+
+// Set sets the clock to the time, and wakes up the waiters whose durations
+// have elapsed.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.set(t)
+}
+
+func (c *FakeClock) set(t time.Time) {
+	c.now = t
+	waiters := []fakeClockWaiter{}
+	for _, w := range c.waiters {
+		if w.until.After(t) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.c <- t
+	}
+	c.waiters = waiters
+}
+
+// Waiters returns the number of the channels returned by After() which have
+// not received yet.  It is useful to wait until a handler starts waiting
+// before advancing the clock.
+func (c *FakeClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
@@ -0,0 +1,76 @@
+package fakehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(start)
+
+	c1 := c.After(time.Second)
+	c2 := c.After(2 * time.Second)
+	if got := c.Waiters(); got != 2 {
+		t.Fatalf("want 2 waiters, but got %v", got)
+	}
+
+	c.Advance(time.Second)
+	if got := <-c1; !got.Equal(start.Add(time.Second)) {
+		t.Fatalf("want %v, but got %v", start.Add(time.Second), got)
+	}
+	select {
+	case <-c2:
+		t.Fatalf("should not receive before the duration elapses")
+	default:
+	}
+
+	c.Set(start.Add(time.Minute))
+	<-c2
+	if got := c.Waiters(); got != 0 {
+		t.Fatalf("want no waiters, but got %v", got)
+	}
+	if got := c.Now(); !got.Equal(start.Add(time.Minute)) {
+		t.Fatalf("want %v, but got %v", start.Add(time.Minute), got)
+	}
+	<-c.After(0)
+}
+
+func TestMultipleHandler_ServeHTTP_clock(t *testing.T) {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	h := NewMultipleHandler([]JSONHandler{
+		{Method: "GET", PathFmt: "/users", ResponseCode: 204},
+	})
+	h.Journal = &Journal{}
+	h.Latency = &Latency{FirstByte: FixedDelay(time.Hour)}
+	h.Clock = clock
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users", nil))
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Hour)
+	<-done
+
+	if w.Code != 204 {
+		t.Fatalf("want 204, but got %v", w.Code)
+	}
+	if got, want := w.Header().Get("Date"), start.Format(http.TimeFormat); got != want {
+		t.Fatalf("want Date %v, but got %v", want, got)
+	}
+	entries := h.Journal.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("want 1 entry, but got %v", entries)
+	}
+	if !entries[0].Time.Equal(start) || entries[0].Duration != time.Hour {
+		t.Fatalf("want the time of the clock, but got %v and %v", entries[0].Time, entries[0].Duration)
+	}
+}
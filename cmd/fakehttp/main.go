@@ -0,0 +1,302 @@
+// Command fakehttp serves fake HTTP handlers defined in stub files, so that
+// the same fakes used in `go test` can be used by other processes.
+// The admin API of fakehttp.AdminHandler is served under /__admin.
+//
+// Usage:
+//
+//	fakehttp [flags]
+//
+// The flags are:
+//
+//	-addr string
+//		address to listen on (default ":8080")
+//	-stubs string
+//		directory of JSON/YAML stub files
+//	-wiremock string
+//		WireMock root directory containing mappings and __files
+//	-tls-cert string, -tls-key string
+//		certificate and key files to serve HTTPS
+//	-v int
+//		log verbosity: 0 logs errors only, 1 logs requests, 2 logs requests
+//		with headers (default 1)
+//	-watch duration
+//		interval to check the stub directory for changes and reload it; 0
+//		disables hot reload
+//	-fallback string
+//		URL of a real server to forward the requests not matching any stub
+//	-record string
+//		upstream URL to forward requests to; the recorded stubs are written
+//		to the -stubs directory on interrupt
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/tennashi/fakehttp"
+)
+
+type config struct {
+	addr        string
+	stubsDir    string
+	wireMockDir string
+	certFile    string
+	keyFile     string
+	verbosity   int
+	watch       time.Duration
+	record      string
+	fallback    string
+}
+
+func main() {
+	cfg := config{}
+	flag.StringVar(&cfg.addr, "addr", ":8080", "address to listen on")
+	flag.StringVar(&cfg.stubsDir, "stubs", "", "directory of JSON/YAML stub files")
+	flag.StringVar(&cfg.wireMockDir, "wiremock", "", "WireMock root directory containing mappings and __files")
+	flag.StringVar(&cfg.certFile, "tls-cert", "", "certificate file to serve HTTPS")
+	flag.StringVar(&cfg.keyFile, "tls-key", "", "key file to serve HTTPS")
+	flag.IntVar(&cfg.verbosity, "v", 1, "log verbosity: 0 logs errors only, 1 logs requests, 2 logs requests with headers")
+	flag.DurationVar(&cfg.watch, "watch", 0, "interval to check the stub directory for changes and reload it; 0 disables hot reload")
+	flag.StringVar(&cfg.fallback, "fallback", "", "URL of a real server to forward the requests not matching any stub")
+	flag.StringVar(&cfg.record, "record", "", "upstream URL to forward requests to; the recorded stubs are written to the -stubs directory on interrupt")
+	flag.Parse()
+
+	if err := run(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, "fakehttp:", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg config) error {
+	if (cfg.stubsDir == "") == (cfg.wireMockDir == "") {
+		return errors.New("exactly one of -stubs and -wiremock must be specified")
+	}
+	if (cfg.certFile == "") != (cfg.keyFile == "") {
+		return errors.New("both -tls-cert and -tls-key must be specified")
+	}
+	if cfg.record != "" && cfg.stubsDir == "" {
+		return errors.New("-record requires -stubs")
+	}
+
+	var h http.Handler
+	if cfg.record != "" {
+		rec, err := record(cfg)
+		if err != nil {
+			return err
+		}
+		h = rec
+	} else {
+		rh := &reloadableHandler{}
+		if err := rh.reload(cfg); err != nil {
+			return err
+		}
+		if cfg.watch > 0 {
+			go rh.watch(cfg)
+		}
+		h = rh
+	}
+
+	srv := &http.Server{
+		Addr:    cfg.addr,
+		Handler: logHandler(h, cfg.verbosity),
+	}
+	log.Printf("listening on %v", cfg.addr)
+	if cfg.certFile != "" {
+		return srv.ListenAndServeTLS(cfg.certFile, cfg.keyFile)
+	}
+	return srv.ListenAndServe()
+}
+
+// load creates the handler serving the stub directory.
+func load(cfg config) (*fakehttp.MultipleHandler, error) {
+	var h *fakehttp.MultipleHandler
+	if cfg.wireMockDir != "" {
+		var unsupported []string
+		var err error
+		h, unsupported, err = fakehttp.LoadWireMock(cfg.wireMockDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, msg := range unsupported {
+			log.Printf("unsupported: %v", msg)
+		}
+	} else {
+		var err error
+		h, err = fakehttp.LoadStubs(cfg.stubsDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.fallback != "" {
+		fallback, err := fakehttp.NewReverseProxy(cfg.fallback)
+		if err != nil {
+			return nil, err
+		}
+		h.Fallback = fallback
+	}
+	return h, nil
+}
+
+// record creates a recorder and saves the recorded stubs to the stub directory
+// when the process is interrupted.
+func record(cfg config) (*fakehttp.Recorder, error) {
+	rec, err := fakehttp.NewRecorder(cfg.record)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(cfg.stubsDir, 0755); err != nil {
+		return nil, err
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		if err := rec.SaveStubs(cfg.stubsDir); err != nil {
+			log.Printf("record: %v", err)
+			os.Exit(1)
+		}
+		log.Printf("saved %v stubs to %v", len(rec.Stubs()), cfg.stubsDir)
+		os.Exit(0)
+	}()
+	return rec, nil
+}
+
+func (cfg config) dir() string {
+	if cfg.wireMockDir != "" {
+		return cfg.wireMockDir
+	}
+	return cfg.stubsDir
+}
+
+// reloadableHandler is an http.Handler whose stubs can be reloaded while
+// serving.  The admin API is mounted under fakehttp.AdminPrefix, and the stubs
+// added with it, the journal and the scenario states survive reloads.
+type reloadableHandler struct {
+	mu    sync.RWMutex
+	admin *fakehttp.AdminHandler
+}
+
+func (h *reloadableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	admin := h.admin
+	h.mu.RUnlock()
+	admin.ServeHTTP(w, r)
+}
+
+func (h *reloadableHandler) reload(cfg config) error {
+	handler, err := load(cfg)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.admin == nil {
+		h.admin = fakehttp.NewAdminHandler(handler)
+		return nil
+	}
+	h.admin.ReplaceInitial(handler)
+	return nil
+}
+
+// watch polls the stub directory and reloads the handler when it changes.
+// If reloading fails, the previous handler keeps serving.
+func (h *reloadableHandler) watch(cfg config) {
+	prev, err := snapshot(cfg.dir())
+	if err != nil {
+		log.Printf("watch: %v", err)
+	}
+	for range time.Tick(cfg.watch) {
+		cur, err := snapshot(cfg.dir())
+		if err != nil {
+			log.Printf("watch: %v", err)
+			continue
+		}
+		if cur == prev {
+			continue
+		}
+		prev = cur
+		if err := h.reload(cfg); err != nil {
+			log.Printf("reload: %v", err)
+			continue
+		}
+		log.Printf("reloaded %v", cfg.dir())
+	}
+}
+
+// snapshot returns a string which changes when a file in the directory is
+// added, removed or modified.
+func snapshot(dir string) (string, error) {
+	entries := []string{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		entries = append(entries, fmt.Sprintf("%v %v %v", p, info.Size(), info.ModTime().UnixNano()))
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, "\n"), nil
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (w *statusRecorder) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Flush passes through to the underlying http.ResponseWriter so that
+// streaming responses are not buffered.
+func (w *statusRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack passes through to the underlying http.ResponseWriter so that
+// faults can close the connection with logging enabled.
+func (w *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack is not supported")
+	}
+	return h.Hijack()
+}
+
+func logHandler(h http.Handler, verbosity int) http.Handler {
+	if verbosity <= 0 {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		h.ServeHTTP(rec, r)
+		log.Printf("%v %v %v %v", r.Method, r.URL.RequestURI(), rec.statusCode, time.Since(start))
+		if verbosity >= 2 {
+			for k, vs := range r.Header {
+				log.Printf("  %v: %v", k, strings.Join(vs, ", "))
+			}
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadableHandler_watch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fakehttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	write := func(status string) {
+		content := `{"request": {"method": "GET", "path": "/users"}, "response": {"status": ` + status + `}}`
+		if err := ioutil.WriteFile(filepath.Join(dir, "users.json"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(h *reloadableHandler) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users", nil))
+		return w.Result().StatusCode
+	}
+
+	write("200")
+	cfg := config{stubsDir: dir, watch: 10 * time.Millisecond}
+	h := &reloadableHandler{}
+	if err := h.reload(cfg); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if got := get(h); got != 200 {
+		t.Fatalf("want 200, but got %v", got)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost/__admin/stubs", strings.NewReader(
+		`{"request": {"method": "GET", "path": "/admin-stub"}, "response": {"status": 202}}`)))
+	if w.Code != 201 {
+		t.Fatalf("want 201, but got %v", w.Code)
+	}
+
+	go h.watch(cfg)
+	// Make sure the modification time changes on coarse-grained filesystems.
+	time.Sleep(20 * time.Millisecond)
+	write("201")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for get(h) != 201 {
+		if time.Now().After(deadline) {
+			t.Fatalf("want 201 after reload, but got %v", get(h))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The stubs added with the admin API and the journal survive reloads.
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/admin-stub", nil))
+	if w.Code != 202 {
+		t.Fatalf("want 202 from the admin stub after reload, but got %v", w.Code)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/__admin/requests", nil))
+	if !strings.Contains(w.Body.String(), `"URL":"/admin-stub"`) || !strings.Contains(w.Body.String(), `"StatusCode":200`) {
+		t.Fatalf("want the journal to survive reload, but got %v", w.Body.String())
+	}
+}
+
+func TestRun_invalidFlags(t *testing.T) {
+	cases := []config{
+		{},
+		{stubsDir: "a", wireMockDir: "b"},
+		{stubsDir: "a", certFile: "cert.pem"},
+	}
+	for _, cfg := range cases {
+		t.Run("", func(t *testing.T) {
+			if err := run(cfg); err == nil {
+				t.Fatalf("should be error, but not")
+			}
+		})
+	}
+}
+
+func TestLogHandler_flush(t *testing.T) {
+	var flushed, hijackable bool
+	h := logHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, flushed = w.(http.Flusher)
+		_, hijackable = w.(http.Hijacker)
+	}), 1)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost/", nil))
+	if !flushed || !hijackable {
+		t.Fatalf("want http.Flusher and http.Hijacker, but got %v and %v", flushed, hijackable)
+	}
+}
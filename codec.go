@@ -0,0 +1,274 @@
+package fakehttp
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Codec encodes and decodes the HTTP bodies of a media type.
+type Codec interface {
+	// ContentType returns the value of the Content-Type header such as
+	// "application/json".
+	ContentType() string
+	// Decode decodes the body into v, which is a pointer.
+	Decode(r io.Reader, v interface{}) error
+	// Encode encodes v into a body.
+	Encode(v interface{}) ([]byte, error)
+}
+
+// The built-in codecs.
+var (
+	// JSONCodec encodes and decodes application/json.
+	JSONCodec Codec = jsonCodec{}
+	// XMLCodec encodes and decodes application/xml with encoding/xml.
+	XMLCodec Codec = xmlCodec{}
+	// FormCodec encodes and decodes application/x-www-form-urlencoded.  It
+	// decodes into *url.Values, *map[string]string, *map[string][]string or
+	// *interface{}, or other types via JSON with each field as a string.  It
+	// encodes url.Values, map[string]string and map[string][]string, or other
+	// types via JSON.
+	FormCodec Codec = formCodec{}
+	// TextCodec encodes and decodes text/plain.  It decodes into *string,
+	// *[]byte or *interface{}, and encodes any value with fmt.Sprint() except
+	// []byte.
+	TextCodec Codec = textCodec{}
+	// YAMLCodec encodes and decodes application/yaml.
+	YAMLCodec Codec = yamlCodec{}
+)
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string {
+	return "application/json"
+}
+
+func (jsonCodec) Decode(r io.Reader, v interface{}) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+func (jsonCodec) Encode(v interface{}) ([]byte, error) {
+	return encodeJSON(v)
+}
+
+type xmlCodec struct{}
+
+func (xmlCodec) ContentType() string {
+	return "application/xml"
+}
+
+func (xmlCodec) Decode(r io.Reader, v interface{}) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+func (xmlCodec) Encode(v interface{}) ([]byte, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+type formCodec struct{}
+
+func (formCodec) ContentType() string {
+	return "application/x-www-form-urlencoded"
+}
+
+func (formCodec) Decode(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	values, err := url.ParseQuery(string(b))
+	if err != nil {
+		return err
+	}
+
+	m := map[string]interface{}{}
+	for k := range values {
+		m[k] = values.Get(k)
+	}
+	switch v := v.(type) {
+	case *url.Values:
+		*v = values
+	case *map[string][]string:
+		*v = values
+	case *map[string]string:
+		*v = map[string]string{}
+		for k := range values {
+			(*v)[k] = values.Get(k)
+		}
+	case *interface{}:
+		*v = m
+	default:
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(b, v)
+	}
+	return nil
+}
+
+func (formCodec) Encode(v interface{}) ([]byte, error) {
+	values := url.Values{}
+	switch v := v.(type) {
+	case url.Values:
+		values = v
+	case map[string][]string:
+		values = v
+	case map[string]string:
+		for k, s := range v {
+			values.Set(k, s)
+		}
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		m := map[string]interface{}{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			return nil, fmt.Errorf("form: %T is not an object", v)
+		}
+		for k, e := range m {
+			if e != nil {
+				values.Set(k, fmt.Sprint(e))
+			}
+		}
+	}
+	return []byte(values.Encode()), nil
+}
+
+type textCodec struct{}
+
+func (textCodec) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+func (textCodec) Decode(r io.Reader, v interface{}) error {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case *string:
+		*v = string(b)
+	case *[]byte:
+		*v = b
+	case *interface{}:
+		*v = string(b)
+	default:
+		return fmt.Errorf("text: cannot decode into %T", v)
+	}
+	return nil
+}
+
+func (textCodec) Encode(v interface{}) ([]byte, error) {
+	if b, ok := v.([]byte); ok {
+		return b, nil
+	}
+	return []byte(fmt.Sprint(v)), nil
+}
+
+type yamlCodec struct{}
+
+func (yamlCodec) ContentType() string {
+	return "application/yaml"
+}
+
+func (yamlCodec) Decode(r io.Reader, v interface{}) error {
+	return yaml.NewDecoder(r).Decode(v)
+}
+
+func (yamlCodec) Encode(v interface{}) ([]byte, error) {
+	return yaml.Marshal(v)
+}
+
+// mediaType returns the media type of a Content-Type without parameters.
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
+
+// codecForContentType returns the codec of the Content-Type, or nil if there
+// is no such codec.
+func codecForContentType(codecs []Codec, contentType string) Codec {
+	mt := mediaType(contentType)
+	for _, c := range codecs {
+		if mediaType(c.ContentType()) == mt {
+			return c
+		}
+	}
+	return nil
+}
+
+// codecForAccept returns the codec most preferred by the Accept header, or
+// nil if none is acceptable.  The first codec is preferred if the header is
+// empty or the preferences are equal.
+func codecForAccept(codecs []Codec, accept string) Codec {
+	if strings.TrimSpace(accept) == "" {
+		if len(codecs) == 0 {
+			return nil
+		}
+		return codecs[0]
+	}
+
+	type candidate struct {
+		codec Codec
+		q     float64
+	}
+	candidates := []candidate{}
+	for _, c := range codecs {
+		mt := mediaType(c.ContentType())
+		// The q value of the most specific media range matching mt is used.
+		best, specificity := 0.0, 0
+		for _, v := range strings.Split(accept, ",") {
+			rangeType, params, err := mime.ParseMediaType(strings.TrimSpace(v))
+			if err != nil {
+				continue
+			}
+			s := 0
+			switch {
+			case rangeType == mt:
+				s = 3
+			case strings.HasSuffix(rangeType, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(rangeType, "*")):
+				s = 2
+			case rangeType == "*/*":
+				s = 1
+			}
+			if s <= specificity {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+			best, specificity = q, s
+		}
+		if best > 0 {
+			candidates = append(candidates, candidate{codec: c, q: best})
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].codec
+}
@@ -0,0 +1,117 @@
+package fakehttp
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testItem struct {
+	Name string `json:"name" xml:"name" yaml:"name"`
+}
+
+func TestJSONHandler_ServeHTTP_codecs(t *testing.T) {
+	cases := []struct {
+		name            string
+		contentType     string
+		body            string
+		accept          string
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{name: "json", contentType: "application/json", body: `{"name":"a"}`, wantCode: 200, wantContentType: "application/json", wantBody: `{"name":"a"}` + "\n"},
+		{name: "xml", contentType: "application/xml; charset=utf-8", body: `<testItem><name>a</name></testItem>`, accept: "application/xml", wantCode: 200, wantContentType: "application/xml", wantBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<testItem><name>a</name></testItem>`},
+		{name: "form", contentType: "application/x-www-form-urlencoded", body: `name=a`, accept: "application/x-www-form-urlencoded", wantCode: 200, wantContentType: "application/x-www-form-urlencoded", wantBody: `name=a`},
+		{name: "yaml", contentType: "application/yaml", body: `name: a`, accept: "application/*", wantCode: 200, wantContentType: "application/json", wantBody: `{"name":"a"}` + "\n"},
+		{name: "accept_q", contentType: "application/json", body: `{"name":"a"}`, accept: "application/json;q=0.5, application/yaml", wantCode: 200, wantContentType: "application/yaml", wantBody: "name: a\n"},
+		{name: "accept_specific", contentType: "application/json", body: `{"name":"a"}`, accept: "application/json;q=0, */*", wantCode: 200, wantContentType: "application/xml"},
+		{name: "unsupported_media_type", contentType: "text/csv", body: `a`, wantCode: 415},
+		{name: "not_acceptable", contentType: "application/json", body: `{"name":"a"}`, accept: "text/csv", wantCode: 406},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := JSONHandler{
+				Method:         "POST",
+				PathFmt:        "/items",
+				NewRequestBody: func() interface{} { return &testItem{} },
+				ResponseCode:   200,
+				Codecs:         []Codec{JSONCodec, XMLCodec, FormCodec, YAMLCodec},
+				ResponseFn: func(body interface{}, _ []string, _ url.Values) (interface{}, error) {
+					return body, nil
+				},
+			}
+			r := httptest.NewRequest("POST", "http://localhost/items", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			r.Header.Set("Accept", tt.accept)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("want %v, but got %v: %v", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantContentType == "" {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Fatalf("want Content-Type %v, but got %v", tt.wantContentType, got)
+			}
+			if tt.wantBody == "" {
+				return
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Fatalf("want %q, but got %q", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestFormCodec(t *testing.T) {
+	var values url.Values
+	if err := FormCodec.Decode(strings.NewReader("a=1&a=2&b=3"), &values); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if want := (url.Values{"a": {"1", "2"}, "b": {"3"}}); !reflect.DeepEqual(values, want) {
+		t.Fatalf("want %v, but got %v", want, values)
+	}
+
+	var m interface{}
+	if err := FormCodec.Decode(strings.NewReader("a=1"), &m); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if want := map[string]interface{}{"a": "1"}; !reflect.DeepEqual(m, want) {
+		t.Fatalf("want %v, but got %v", want, m)
+	}
+
+	b, err := FormCodec.Encode(map[string]interface{}{"a": 1, "b": "x y"})
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if got := string(b); got != "a=1&b=x+y" {
+		t.Fatalf("unexpected encoding: %v", got)
+	}
+	if _, err := FormCodec.Encode([]int{1}); err == nil {
+		t.Fatalf("should be error, but not")
+	}
+}
+
+func TestTextCodec(t *testing.T) {
+	var s string
+	if err := TextCodec.Decode(strings.NewReader("hello"), &s); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if s != "hello" {
+		t.Fatalf("want hello, but got %v", s)
+	}
+	if err := TextCodec.Decode(strings.NewReader("hello"), &testItem{}); err == nil {
+		t.Fatalf("should be error, but not")
+	}
+
+	b, _ := TextCodec.Encode(42)
+	if got := string(b); got != "42" {
+		t.Fatalf("want 42, but got %v", got)
+	}
+}
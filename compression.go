@@ -0,0 +1,113 @@
+package fakehttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Compression specifies how JSONHandler compresses the response body.
+type Compression struct {
+	// Force is the Content-Encoding used regardless of Accept-Encoding, which
+	// is "gzip" or "deflate".  If it is an empty string, the encoding is
+	// negotiated with Accept-Encoding, and the body is not compressed unless
+	// the client accepts gzip or deflate.
+	Force string
+	// Corrupt makes the compressed body fail to decompress by breaking its
+	// checksum, to test how clients handle corrupt responses.
+	Corrupt bool
+}
+
+// encoding returns the Content-Encoding of the response to the request, or an
+// empty string if the body should not be compressed.
+func (c *Compression) encoding(r *http.Request) string {
+	if c == nil {
+		return ""
+	}
+	if c.Force != "" {
+		return c.Force
+	}
+
+	qs := map[string]float64{}
+	for _, v := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		parts := strings.Split(v, ";")
+		enc := strings.ToLower(strings.TrimSpace(parts[0]))
+		q := 1.0
+		for _, p := range parts[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if f, err := strconv.ParseFloat(p[2:], 64); err == nil {
+					q = f
+				}
+			}
+		}
+		qs[enc] = q
+	}
+
+	// The wildcard applies only to the encodings not listed explicitly, so
+	// that an encoding refused with q=0 is never chosen.
+	best, bestQ := "", 0.0
+	for _, enc := range []string{"gzip", "deflate"} {
+		q, ok := qs[enc]
+		if !ok {
+			q = qs["*"]
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// compress compresses b with the Content-Encoding.
+func (c *Compression) compress(b []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding: %v", encoding)
+	}
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	ret := buf.Bytes()
+	if c.Corrupt {
+		// The last byte belongs to the trailer of gzip or zlib, which is
+		// verified after the body is decompressed.
+		ret[len(ret)-1] ^= 0xff
+	}
+	return ret, nil
+}
+
+// errUnsupportedEncoding is returned by decodedBody if the Content-Encoding of
+// the request is unknown.
+var errUnsupportedEncoding = errors.New("unsupported Content-Encoding")
+
+// decodedBody returns the request body decompressed according to its
+// Content-Encoding.  It returns errUnsupportedEncoding if the Content-Encoding
+// is unknown, and the error of the decompressor if the body is malformed.
+func decodedBody(r *http.Request) (io.ReadCloser, error) {
+	switch enc := strings.ToLower(r.Header.Get("Content-Encoding")); enc {
+	case "", "identity":
+		return r.Body, nil
+	case "gzip":
+		return gzip.NewReader(r.Body)
+	case "deflate":
+		return zlib.NewReader(r.Body)
+	default:
+		return nil, fmt.Errorf("%w: %v", errUnsupportedEncoding, enc)
+	}
+}
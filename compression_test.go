@@ -0,0 +1,148 @@
+package fakehttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestJSONHandler_ServeHTTP_compression(t *testing.T) {
+	cases := []struct {
+		name           string
+		compression    *Compression
+		acceptEncoding string
+		want           string
+	}{
+		{name: "none", compression: nil, acceptEncoding: "gzip", want: ""},
+		{name: "not_accepted", compression: &Compression{}, acceptEncoding: "br", want: ""},
+		{name: "gzip", compression: &Compression{}, acceptEncoding: "gzip, deflate", want: "gzip"},
+		{name: "deflate", compression: &Compression{}, acceptEncoding: "gzip;q=0.5, deflate", want: "deflate"},
+		{name: "refused", compression: &Compression{}, acceptEncoding: "gzip;q=0", want: ""},
+		{name: "wildcard", compression: &Compression{}, acceptEncoding: "*", want: "gzip"},
+		{name: "wildcard_refused", compression: &Compression{}, acceptEncoding: "gzip;q=0, *", want: "deflate"},
+		{name: "wildcard_all_refused", compression: &Compression{}, acceptEncoding: "gzip;q=0, deflate;q=0, *;q=1", want: ""},
+		{name: "forced", compression: &Compression{Force: "deflate"}, acceptEncoding: "", want: "deflate"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := JSONHandler{
+				Method:       "GET",
+				PathFmt:      "/users",
+				ResponseCode: 200,
+				Compression:  tt.compression,
+				ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+					return map[string]string{"name": "test-user"}, nil
+				},
+			}
+			r := httptest.NewRequest("GET", "http://localhost/users", nil)
+			r.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if got := w.Header().Get("Content-Encoding"); got != tt.want {
+				t.Fatalf("want Content-Encoding %q, but got %q", tt.want, got)
+			}
+			b, err := decompress(w.Body.Bytes(), tt.want)
+			if err != nil {
+				t.Fatalf("should not be error, but: %v", err)
+			}
+			if got := string(b); got != `{"name":"test-user"}`+"\n" {
+				t.Fatalf("unexpected body: %v", got)
+			}
+		})
+	}
+}
+
+func TestJSONHandler_ServeHTTP_corruptCompression(t *testing.T) {
+	for _, enc := range []string{"gzip", "deflate"} {
+		t.Run(enc, func(t *testing.T) {
+			h := JSONHandler{
+				Method:       "GET",
+				PathFmt:      "/users",
+				ResponseCode: 200,
+				Compression:  &Compression{Force: enc, Corrupt: true},
+				ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+					return map[string]string{"name": "test-user"}, nil
+				},
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users", nil))
+			if _, err := decompress(w.Body.Bytes(), enc); err == nil {
+				t.Fatalf("should be error, but not")
+			}
+		})
+	}
+}
+
+func TestJSONHandler_ServeHTTP_compressedRequest(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(`{"name":"test-user"}`))
+	zw.Close()
+
+	var got map[string]string
+	h := JSONHandler{
+		Method:       "POST",
+		PathFmt:      "/users",
+		RequestBody:  &got,
+		ResponseCode: 201,
+	}
+	r := httptest.NewRequest("POST", "http://localhost/users", &buf)
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 201 {
+		t.Fatalf("want 201, but got %v: %v", w.Code, w.Body.String())
+	}
+	if got["name"] != "test-user" {
+		t.Fatalf("unexpected request body: %v", got)
+	}
+
+	r = httptest.NewRequest("POST", "http://localhost/users", bytes.NewReader([]byte("{}")))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Content-Encoding", "br")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("want 415, but got %v", w.Code)
+	}
+
+	for _, enc := range []string{"gzip", "deflate"} {
+		for _, body := range []string{"", "not compressed"} {
+			r = httptest.NewRequest("POST", "http://localhost/users", strings.NewReader(body))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Content-Encoding", enc)
+			w = httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("%v %q: want 400, but got %v", enc, body, w.Code)
+			}
+		}
+	}
+}
+
+func decompress(b []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "gzip":
+		r, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	case "deflate":
+		r, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.ReadAll(r)
+	}
+	return b, nil
+}
@@ -0,0 +1,104 @@
+package fakehttp
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// generateETag returns a strong ETag of the encoded response body.
+func generateETag(b []byte) string {
+	return fmt.Sprintf(`"%x"`, sha1.Sum(b))
+}
+
+// checkConditions evaluates the preconditions of the request against the
+// ETag and Last-Modified of the current state of the resource, in the order
+// defined in RFC 7232 Section 6.  It returns 304 Not Modified or 412
+// Precondition Failed if the request should not be served, or 0 otherwise.
+func checkConditions(r *http.Request, etag string, lastModified time.Time) int {
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+
+	if v := r.Header.Get("If-Match"); v != "" {
+		if !etagMatches(v, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		if lastModified.Truncate(time.Second).After(t) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	if v := r.Header.Get("If-None-Match"); v != "" {
+		if etagMatches(v, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if t, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && safe && !lastModified.IsZero() {
+		if !lastModified.Truncate(time.Second).After(t) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// encodedETag returns the ETag of the response body compressed with the
+// content-coding, e.g. `"abc-gzip"` for `"abc"`, or etag itself if encoding is
+// an empty string.
+func encodedETag(etag, encoding string) string {
+	if etag == "" || encoding == "" || !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// decodedETag returns the ETag without the suffix added by encodedETag().
+func decodedETag(etag string) string {
+	for _, encoding := range []string{"gzip", "deflate"} {
+		suffix := "-" + encoding + `"`
+		if strings.HasSuffix(etag, suffix) {
+			return strings.TrimSuffix(etag, suffix) + `"`
+		}
+	}
+	return etag
+}
+
+// etagMatches reports whether the value of an If-Match or If-None-Match
+// header matches the ETag.  The weak comparison is used if weak is true, and
+// the strong comparison otherwise.  The ETags of the compressed
+// representations given by encodedETag() match the ETag too, since they
+// identify the same state of the resource.
+func etagMatches(header, etag string, weak bool) bool {
+	if strings.TrimSpace(header) == "*" {
+		return etag != ""
+	}
+	if etag == "" || (!weak && strings.HasPrefix(etag, "W/")) {
+		return false
+	}
+	for _, v := range strings.Split(header, ",") {
+		v = decodedETag(strings.TrimSpace(v))
+		if weak {
+			if strings.TrimPrefix(v, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if v == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// setValidators sets the ETag and Last-Modified headers unless they are empty.
+func setValidators(w http.ResponseWriter, etag string, lastModified time.Time) {
+	if etag != "" {
+		w.Header().Set("ETag", etag)
+	}
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+}
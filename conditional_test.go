@@ -0,0 +1,171 @@
+package fakehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONHandler_ServeHTTP_conditional(t *testing.T) {
+	h := JSONHandler{
+		Method:       "GET",
+		PathFmt:      "/users/1",
+		ResponseCode: 200,
+		Conditional:  true,
+		ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+			return map[string]string{"name": "test-user"}, nil
+		},
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users/1", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != 200 || etag == "" {
+		t.Fatalf("want 200 with ETag, but got %v %v", w.Code, w.Header())
+	}
+
+	cases := []struct {
+		ifNoneMatch string
+		want        int
+	}{
+		{ifNoneMatch: etag, want: 304},
+		{ifNoneMatch: `"other", W/` + etag, want: 304},
+		{ifNoneMatch: `"other"`, want: 200},
+	}
+	for _, tt := range cases {
+		r := httptest.NewRequest("GET", "http://localhost/users/1", nil)
+		r.Header.Set("If-None-Match", tt.ifNoneMatch)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Fatalf("%v: want %v, but got %v", tt.ifNoneMatch, tt.want, w.Code)
+		}
+		if tt.want == 304 && w.Body.Len() != 0 {
+			t.Fatalf("want no body, but got %v", w.Body.String())
+		}
+	}
+}
+
+func TestJSONHandler_ServeHTTP_validators(t *testing.T) {
+	lastModified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	validators := func(_ []string) (string, time.Time) {
+		return `"v1"`, lastModified
+	}
+	called := 0
+	responseFn := func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+		called++
+		return map[string]string{"name": "test-user"}, nil
+	}
+
+	cases := []struct {
+		name   string
+		method string
+		header http.Header
+		want   int
+	}{
+		{name: "get", method: "GET", want: 200},
+		{name: "if_modified_since", method: "GET", header: http.Header{"If-Modified-Since": {lastModified.Format(http.TimeFormat)}}, want: 304},
+		{name: "modified_since", method: "GET", header: http.Header{"If-Modified-Since": {lastModified.Add(-time.Hour).Format(http.TimeFormat)}}, want: 200},
+		{name: "if_none_match_wins", method: "GET", header: http.Header{"If-None-Match": {`"v0"`}, "If-Modified-Since": {lastModified.Format(http.TimeFormat)}}, want: 200},
+		{name: "if_match", method: "PUT", header: http.Header{"If-Match": {`"v1"`}}, want: 200},
+		{name: "if_match_failed", method: "PUT", header: http.Header{"If-Match": {`"v0"`}}, want: 412},
+		{name: "if_unmodified_since_failed", method: "PUT", header: http.Header{"If-Unmodified-Since": {lastModified.Add(-time.Hour).Format(http.TimeFormat)}}, want: 412},
+		{name: "if_none_match_any", method: "PUT", header: http.Header{"If-None-Match": {"*"}}, want: 412},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			called = 0
+			h := JSONHandler{
+				Method:       tt.method,
+				PathFmt:      "/users/1",
+				ResponseCode: 200,
+				Conditional:  true,
+				Validators:   validators,
+				ResponseFn:   responseFn,
+			}
+			r := httptest.NewRequest(tt.method, "http://localhost/users/1", nil)
+			for k, v := range tt.header {
+				r.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Fatalf("want %v, but got %v", tt.want, w.Code)
+			}
+			if wantCalled := map[bool]int{true: 1, false: 0}[tt.want == 200]; called != wantCalled {
+				t.Fatalf("want ResponseFn called %v times, but got %v", wantCalled, called)
+			}
+			if tt.method == "GET" && w.Header().Get("ETag") != `"v1"` {
+				t.Fatalf("want ETag, but got %v", w.Header())
+			}
+			if tt.method == "GET" && w.Header().Get("Last-Modified") != lastModified.Format(http.TimeFormat) {
+				t.Fatalf("want Last-Modified, but got %v", w.Header())
+			}
+		})
+	}
+}
+
+func TestResource_conditional(t *testing.T) {
+	res := NewResource("/users", testUser{})
+	res.Put("1", testUser{Name: "a"})
+	h := NewMultipleHandler(res.Handlers())
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users/1", nil))
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("want ETag, but got %v", w.Header())
+	}
+
+	put := func(ifMatch string) int {
+		r := httptest.NewRequest("PUT", "http://localhost/users/1", strings.NewReader(`{"name":"b"}`))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set("If-Match", ifMatch)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+	if got := put(etag); got != 200 {
+		t.Fatalf("want 200, but got %v", got)
+	}
+	if got := put(etag); got != 412 {
+		t.Fatalf("want 412 for the stale ETag, but got %v", got)
+	}
+}
+
+func TestJSONHandler_ServeHTTP_conditionalCompression(t *testing.T) {
+	h := JSONHandler{
+		Method:       "GET",
+		PathFmt:      "/users/1",
+		ResponseCode: 200,
+		Conditional:  true,
+		Compression:  &Compression{},
+		ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+			return map[string]string{"name": "test-user"}, nil
+		},
+	}
+	get := func(acceptEncoding, ifNoneMatch string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "http://localhost/users/1", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		r.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	identity := get("", "").Header().Get("ETag")
+	gzipped := get("gzip", "").Header().Get("ETag")
+	if identity == "" || gzipped != strings.TrimSuffix(identity, `"`)+`-gzip"` {
+		t.Fatalf("want distinct ETags per content-coding, but got %v and %v", identity, gzipped)
+	}
+	if w := get("gzip", gzipped); w.Code != 304 || w.Header().Get("ETag") != gzipped {
+		t.Fatalf("want 304 with %v, but got %v %v", gzipped, w.Code, w.Header())
+	}
+	if w := get("", gzipped); w.Code != 304 || w.Header().Get("ETag") != identity {
+		t.Fatalf("want 304 with %v, but got %v %v", identity, w.Code, w.Header())
+	}
+}
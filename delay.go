@@ -0,0 +1,164 @@
+package fakehttp
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Delay returns how long to delay a response.
+type Delay interface {
+	Duration() time.Duration
+}
+
+// DelayFunc is an adapter to use a function as a Delay.
+type DelayFunc func() time.Duration
+
+// Duration calls f().
+func (f DelayFunc) Duration() time.Duration {
+	return f()
+}
+
+// FixedDelay is a Delay that always returns the same duration.
+type FixedDelay time.Duration
+
+// Duration returns d.
+func (d FixedDelay) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+// UniformDelay returns a Delay that returns a duration uniformly distributed
+// in [min, max).  The random numbers are generated from the seed, so that the
+// durations are reproducible.
+func UniformDelay(min, max time.Duration, seed int64) Delay {
+	rnd := newLockedRand(seed)
+	return DelayFunc(func() time.Duration {
+		if max <= min {
+			return min
+		}
+		return min + time.Duration(rnd.Int63n(int64(max-min)))
+	})
+}
+
+// LogNormalDelay returns a Delay that returns a duration following a
+// log-normal distribution, which approximates the latency of real servers.
+// median is the median of the distribution and sigma is the standard
+// deviation of the underlying normal distribution.  The random numbers are
+// generated from the seed, so that the durations are reproducible.
+func LogNormalDelay(median time.Duration, sigma float64, seed int64) Delay {
+	rnd := newLockedRand(seed)
+	return DelayFunc(func() time.Duration {
+		return time.Duration(float64(median) * math.Exp(rnd.NormFloat64()*sigma))
+	})
+}
+
+// Latency specifies how long a response takes.
+type Latency struct {
+	// FirstByte delays the response until the header is written.  The
+	// response function is not called if the request is canceled meanwhile.
+	FirstByte Delay
+	// Total is the total duration of the response.  If it is longer than
+	// FirstByte, the body is written in chunks spread over the remaining time.
+	Total Delay
+}
+
+// latencyChunks is the number of chunks in which a body is written when
+// Latency.Total is specified.
+const latencyChunks = 10
+
+func (l *Latency) firstByte() time.Duration {
+	if l == nil || l.FirstByte == nil {
+		return 0
+	}
+	return l.FirstByte.Duration()
+}
+
+func (l *Latency) total() time.Duration {
+	if l == nil || l.Total == nil {
+		return 0
+	}
+	return l.Total.Duration()
+}
+
+// writeBody writes b spreading it over the duration.  It stops writing if the
+// request is canceled.
+func writeBody(ctx context.Context, clock Clock, w http.ResponseWriter, b []byte, d time.Duration) {
+	if d <= 0 || len(b) == 0 {
+		w.Write(b)
+		return
+	}
+
+	n := latencyChunks
+	if len(b) < n {
+		n = len(b)
+	}
+	if n == 1 {
+		if sleep(ctx, clock, d) {
+			w.Write(b)
+		}
+		return
+	}
+	interval := d / time.Duration(n-1)
+	for i := 0; i < n; i++ {
+		if i != 0 && !sleep(ctx, clock, interval) {
+			return
+		}
+		w.Write(b[i*len(b)/n : (i+1)*len(b)/n])
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
+
+// sleep waits for the duration of the clock, or of the system clock if clock
+// is nil, and reports whether the context is still alive.
+func sleep(ctx context.Context, clock Clock, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	var c <-chan time.Time
+	if clock == nil {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		c = t.C
+	} else {
+		c = clock.After(d)
+	}
+	select {
+	case <-c:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// lockedRand is a *rand.Rand safe for concurrent use.
+type lockedRand struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newLockedRand(seed int64) *lockedRand {
+	return &lockedRand{rnd: rand.New(rand.NewSource(seed))}
+}
+
+func (r *lockedRand) Int63n(n int64) int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Int63n(n)
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.Float64()
+}
+
+func (r *lockedRand) NormFloat64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rnd.NormFloat64()
+}
@@ -0,0 +1,121 @@
+package fakehttp
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestUniformDelay(t *testing.T) {
+	min, max := 10*time.Millisecond, 20*time.Millisecond
+	d1 := UniformDelay(min, max, 42)
+	d2 := UniformDelay(min, max, 42)
+	for i := 0; i < 100; i++ {
+		got := d1.Duration()
+		if got < min || got >= max {
+			t.Fatalf("want in [%v, %v), but got %v", min, max, got)
+		}
+		if want := d2.Duration(); got != want {
+			t.Fatalf("want reproducible %v, but got %v", want, got)
+		}
+	}
+}
+
+func TestLogNormalDelay(t *testing.T) {
+	d1 := LogNormalDelay(100*time.Millisecond, 0.5, 42)
+	d2 := LogNormalDelay(100*time.Millisecond, 0.5, 42)
+	for i := 0; i < 100; i++ {
+		got := d1.Duration()
+		if got <= 0 {
+			t.Fatalf("want positive, but got %v", got)
+		}
+		if want := d2.Duration(); got != want {
+			t.Fatalf("want reproducible %v, but got %v", want, got)
+		}
+	}
+
+	if got := LogNormalDelay(100*time.Millisecond, 0, 42).Duration(); got != 100*time.Millisecond {
+		t.Fatalf("want the median without sigma, but got %v", got)
+	}
+}
+
+func TestJSONHandler_ServeHTTP_latency(t *testing.T) {
+	cases := []struct {
+		name    string
+		latency *Latency
+		min     time.Duration
+	}{
+		{name: "none", latency: nil, min: 0},
+		{name: "first_byte", latency: &Latency{FirstByte: FixedDelay(30 * time.Millisecond)}, min: 30 * time.Millisecond},
+		{name: "total", latency: &Latency{Total: FixedDelay(30 * time.Millisecond)}, min: 30 * time.Millisecond},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := JSONHandler{
+				Method:       "GET",
+				PathFmt:      "/users",
+				ResponseCode: 200,
+				Latency:      tt.latency,
+				ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+					return map[string]string{"name": "a long enough name to be split into chunks"}, nil
+				},
+			}
+
+			start := time.Now()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users", nil))
+			if got := time.Since(start); got < tt.min {
+				t.Fatalf("want at least %v, but got %v", tt.min, got)
+			}
+			if got := w.Body.String(); got != `{"name":"a long enough name to be split into chunks"}`+"\n" {
+				t.Fatalf("unexpected body: %v", got)
+			}
+		})
+	}
+}
+
+func TestJSONHandler_ServeHTTP_latencyCanceled(t *testing.T) {
+	called := false
+	h := JSONHandler{
+		Method:       "GET",
+		PathFmt:      "/users",
+		ResponseCode: 200,
+		Latency:      &Latency{FirstByte: FixedDelay(time.Hour)},
+		ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+			called = true
+			return nil, nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("GET", "http://localhost/users", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if called {
+		t.Fatalf("ResponseFn should not be called after the request is canceled")
+	}
+}
+
+func TestMultipleHandler_ServeHTTP_latency(t *testing.T) {
+	h := NewMultipleHandler([]JSONHandler{
+		{Method: "GET", PathFmt: "/slow", ResponseCode: 204},
+		{Method: "GET", PathFmt: "/fast", ResponseCode: 204, Latency: &Latency{}},
+	})
+	h.Latency = &Latency{FirstByte: FixedDelay(30 * time.Millisecond)}
+
+	start := time.Now()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost/fast", nil))
+	if got := time.Since(start); got >= 30*time.Millisecond {
+		t.Fatalf("want handler latency to take precedence, but got %v", got)
+	}
+
+	start = time.Now()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost/slow", nil))
+	if got := time.Since(start); got < 30*time.Millisecond {
+		t.Fatalf("want at least 30ms, but got %v", got)
+	}
+}
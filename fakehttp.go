@@ -1,13 +1,18 @@
 package fakehttp
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
 	"path"
+	"regexp"
 	"strings"
+	"time"
 )
 
 // JSONHandler is a mock of an HTTP handler that sends and recieves JSON.
@@ -16,11 +21,28 @@ type JSONHandler struct {
 	// See path.Match() for possible value patterns.  Skip the URL path check if
 	// it is an empty string.
 	PathFmt string
+	// PathRegexp is a regular expression of URL paths to bind a handler to.
+	// If it is not nil, it is used instead of PathFmt and must match the whole
+	// URL path.  The submatches are passed to ResponseFn as URL path params.
+	PathRegexp *regexp.Regexp `json:"-"`
 	// Method is an HTTP request method.  Skip the HTTP method check if it is an
 	// empty string.
 	Method string
 	// RequestBody specifies the type to decode JSON of the HTTP request body.
+	// The body compressed with gzip or deflate is decompressed according to
+	// the Content-Encoding header.  Specify *MultipartForm to receive a
+	// multipart/form-data body.
 	RequestBody interface{}
+	// MaxRequestBodySize limits the size of the HTTP request body decoded
+	// into RequestBody if it is positive.  413 Request Entity Too Large is
+	// returned if the body exceeds it.  It also limits the body recorded in
+	// Journal and read by Idempotency and the body matchers, which do not
+	// match a larger body.
+	MaxRequestBodySize int64
+	// NewRequestBody returns a new value to decode JSON of the HTTP request
+	// body into.  If it is not nil, it is called for each request instead of
+	// sharing RequestBody among concurrent requests.
+	NewRequestBody func() interface{} `json:"-"`
 	// ResponseCode is an HTTP response code.
 	ResponseCode int
 	// ResponseFn is the function to return the response.
@@ -32,7 +54,71 @@ type JSONHandler struct {
 	// The third argument is a URL query parameter.
 	// The return value is JSON encoded, so it must be a value that can be
 	// specified as an argument to json.Marshal().
+	// If a Response is returned, its StatusCode and Header override
+	// ResponseCode and ResponseHeader, and its Body is encoded instead.
+	// If an error is returned, the response code is 400, or
+	// StatusError.StatusCode if the error is a *StatusError.
 	ResponseFn func(interface{}, []string, url.Values) (interface{}, error) `json:"-"`
+	// ResponseHeader is added to the header of the HTTP response.
+	ResponseHeader http.Header
+	// Matchers are additional conditions that the HTTP request must satisfy.
+	// The request is matched only if all of them return true.
+	Matchers []Matcher `json:"-"`
+	// Latency specifies how long the response takes.  If nil, the response is
+	// returned immediately, or MultipleHandler.Latency is used when served by
+	// MultipleHandler.
+	Latency *Latency `json:"-"`
+	// Fault injects a network-level failure into the response.  If nil, the
+	// response is returned normally.
+	Fault *FaultInjector `json:"-"`
+	// Conditional enables conditional requests.  A successful response to GET
+	// has an ETag generated from the encoded JSON, and 304 Not Modified is
+	// returned if If-None-Match matches it.
+	Conditional bool
+	// Validators returns the ETag, which must be quoted like `"v1"`, and the
+	// Last-Modified time of the current state of the resource identified by
+	// the URL path params.  Either may be a zero value.  If Conditional is
+	// true and it is not nil, If-Match, If-None-Match, If-Modified-Since and
+	// If-Unmodified-Since are evaluated against them before ResponseFn is
+	// called, and 304 Not Modified or 412 Precondition Failed is returned
+	// accordingly.  This also applies to writes for optimistic concurrency.
+	Validators func([]string) (string, time.Time) `json:"-"`
+	// Codecs are the formats of the HTTP request and response bodies.  The
+	// codec of the request body is selected by the Content-Type header, and
+	// that of the response body by the Accept header, responding with 415 or
+	// 406 if there is no such codec.  If nil, the bodies are JSON.
+	Codecs []Codec `json:"-"`
+	// Compression compresses the response body if it is not nil.
+	Compression *Compression `json:"-"`
+	// Idempotency caches the responses per Idempotency-Key header and replays
+	// them on retries if it is not nil.
+	Idempotency *IdempotencyCache `json:"-"`
+	// RateLimit limits the rate of the requests matched by the handler.  Share
+	// the same RateLimiter among handlers to limit them as a group.
+	RateLimit *RateLimiter `json:"-"`
+	// Clock is used by the time-dependent features such as Latency and
+	// RateLimit.  If it is not nil, the Date header of the response is also
+	// set from it.  If nil, the system clock is used, or MultipleHandler.Clock
+	// when served by MultipleHandler.
+	Clock Clock `json:"-"`
+	// Stream streams the response body item by item if it is not nil.  It is
+	// used instead of ResponseFn, Sequence and Fault, and the features that
+	// need the whole body, such as Codecs, Conditional and Compression, do
+	// not apply.
+	Stream *Stream `json:"-"`
+	// Sequence is an ordered list of responses consumed one per request.  If
+	// it is not nil, it is used instead of ResponseFn, and its responses
+	// override ResponseCode and ResponseHeader.
+	Sequence *Sequence `json:"-"`
+	// Scenario is a state machine shared with other handlers.  If it is nil,
+	// RequiredState and NewState are ignored.
+	Scenario *Scenario `json:"-"`
+	// RequiredState is the state of Scenario in which the request is matched.
+	// Skip the state check if it is an empty string.
+	RequiredState string
+	// NewState is the state to which Scenario transitions after responding.
+	// The state does not change if it is an empty string.
+	NewState string
 	// ErrResponseFn specifies how to return an error response.
 	// If nil is specified, a JSON response encoded from the following type is
 	// returned.
@@ -43,9 +129,21 @@ type JSONHandler struct {
 	// }
 	// ```
 	ErrResponseFn func(http.ResponseWriter, error, int) `json:"-"`
+
+	// serve serves the matched HTTP request instead of responding with JSON
+	// if it is not nil.  It is used by the handlers of other protocols
+	// registered to MultipleHandler.
+	serve func(http.ResponseWriter, *http.Request, Clock)
 }
 
 func (h JSONHandler) checkPath(reqPath string) ([]string, error) {
+	if h.PathRegexp != nil {
+		m := h.PathRegexp.FindStringSubmatch(reqPath)
+		if m == nil || m[0] != reqPath {
+			return nil, fmt.Errorf("unmatch path: want %v, got %v", h.PathRegexp, reqPath)
+		}
+		return m[1:], nil
+	}
 	if h.PathFmt == "" {
 		return strings.Split(reqPath, "/"), nil
 	}
@@ -80,6 +178,64 @@ func (h JSONHandler) checkMethod(reqMethod string) error {
 	return nil
 }
 
+func (h JSONHandler) checkScenario() error {
+	if h.Scenario == nil || h.RequiredState == "" {
+		return nil
+	}
+	if state := h.Scenario.State(); state != h.RequiredState {
+		return fmt.Errorf("unmatch scenario state: want %v, got %v", h.RequiredState, state)
+	}
+	return nil
+}
+
+func (h JSONHandler) checkMatchers(r *http.Request) error {
+	if len(h.Matchers) == 0 {
+		return nil
+	}
+	// The body matchers read at most MaxRequestBodySize bytes.
+	mr := r
+	if h.MaxRequestBodySize > 0 {
+		mr = r.WithContext(context.WithValue(r.Context(), bodyLimitKey{}, h.MaxRequestBodySize))
+		defer func() { r.Body = mr.Body }()
+	}
+	for _, m := range h.Matchers {
+		if !m(mr) {
+			return errors.New("unmatch request")
+		}
+	}
+	return nil
+}
+
+// match reports whether the HTTP request should be handled by h.  Unlike
+// ServeHTTP, the Method and PathFmt (or PathRegexp) fields are always
+// compared.
+func (h JSONHandler) match(r *http.Request) (bool, error) {
+	if ok, err := h.matchRoute(r); !ok {
+		return false, err
+	}
+	if err := h.checkScenario(); err != nil {
+		return false, nil
+	}
+	return h.checkMatchers(r) == nil, nil
+}
+
+// matchRoute reports whether the HTTP method and the URL path of the request
+// match h.
+func (h JSONHandler) matchRoute(r *http.Request) (bool, error) {
+	if h.Method != r.Method {
+		return false, nil
+	}
+	if h.PathRegexp != nil {
+		_, err := h.checkPath(r.URL.Path)
+		return err == nil, nil
+	}
+	return path.Match(h.PathFmt, r.URL.Path)
+}
+
+func (h JSONHandler) routable() bool {
+	return h.Method != "" && (h.PathFmt != "" || h.PathRegexp != nil)
+}
+
 func (h JSONHandler) checkContentType(reqContentType string) error {
 	if h.RequestBody == nil {
 		return nil
@@ -103,31 +259,255 @@ func (h JSONHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.checkContentType(r.Header.Get("Content-Type")); err != nil {
-		h.errorResponse(w, err, http.StatusBadRequest)
+	if err := h.checkScenario(); err != nil {
+		h.errorResponse(w, err, http.StatusNotFound)
 		return
 	}
 
+	if err := h.checkMatchers(r); err != nil {
+		h.errorResponse(w, err, http.StatusNotFound)
+		return
+	}
+	h.serveMatched(w, r, params)
+}
+
+// serveMatched serves the request that h matches.  params are the parameters
+// captured from the URL path.
+func (h JSONHandler) serveMatched(w http.ResponseWriter, r *http.Request, params []string) {
+	if h.serve != nil {
+		h.serve(w, r, h.Clock)
+		return
+	}
+
+	if h.Clock != nil {
+		w.Header().Set("Date", h.Clock.Now().UTC().Format(http.TimeFormat))
+	}
+
+	if h.Idempotency != nil {
+		h.Idempotency.serve(w, r, h.MaxRequestBodySize, h.Compression, func(w http.ResponseWriter) {
+			h.respond(w, r, params)
+		}, h.errorResponse)
+		return
+	}
+	h.respond(w, r, params)
+}
+
+// respond writes the response to the request matched by h.
+func (h JSONHandler) respond(w http.ResponseWriter, r *http.Request, params []string) {
+	if h.RateLimit.limit(w, r, h.Clock) {
+		h.errorResponse(w, errRateLimited, http.StatusTooManyRequests)
+		return
+	}
+
+	if h.NewRequestBody != nil {
+		h.RequestBody = h.NewRequestBody()
+	}
+	form, isMultipart := h.RequestBody.(*MultipartForm)
+	reqCodec := JSONCodec
+	if isMultipart {
+		// The Content-Type is checked when the body is parsed.
+	} else if h.Codecs == nil {
+		if err := h.checkContentType(r.Header.Get("Content-Type")); err != nil {
+			h.errorResponse(w, err, http.StatusBadRequest)
+			return
+		}
+	} else if h.RequestBody != nil {
+		reqCodec = codecForContentType(h.Codecs, r.Header.Get("Content-Type"))
+		if reqCodec == nil {
+			h.errorResponse(w, fmt.Errorf("unsupported Content-Type: %v", r.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+	resCodec := JSONCodec
+	if h.Codecs != nil {
+		resCodec = codecForAccept(h.Codecs, r.Header.Get("Accept"))
+		if resCodec == nil {
+			h.errorResponse(w, fmt.Errorf("not acceptable: %v", r.Header.Get("Accept")), http.StatusNotAcceptable)
+			return
+		}
+	}
+
 	if h.RequestBody != nil {
-		if err := json.NewDecoder(r.Body).Decode(h.RequestBody); err != nil {
+		body, err := decodedBody(r)
+		if errors.Is(err, errUnsupportedEncoding) {
+			h.errorResponse(w, err, http.StatusUnsupportedMediaType)
+			return
+		}
+		if err != nil {
 			h.errorResponse(w, err, http.StatusBadRequest)
 			return
 		}
+		limited := limitBody(body, h.MaxRequestBodySize)
+		if isMultipart {
+			form.Parts, err = parseMultipart(r.Header.Get("Content-Type"), limited)
+		} else {
+			err = reqCodec.Decode(limited, h.RequestBody)
+		}
+		if errors.Is(err, errBodyTooLarge) {
+			h.errorResponse(w, err, http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err != nil {
+			h.errorResponse(w, err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	start := now(h.Clock)
+	if !sleep(r.Context(), h.Clock, h.Latency.firstByte()) {
+		return
+	}
+	if h.Stream != nil {
+		if h.Stream.Items == nil {
+			h.errorResponse(w, errors.New("stream has no items"), http.StatusInternalServerError)
+			return
+		}
+		h.Stream.serve(w, r, h, params)
+		if h.Scenario != nil && h.NewState != "" {
+			h.Scenario.SetState(h.NewState)
+		}
+		return
+	}
+	fault := h.Fault.next()
+	if fault == FaultConnectionReset {
+		abort(w, true)
+		return
+	}
+
+	// The ETag differs per content-coding since the body does.
+	enc := h.Compression.encoding(r)
+	etag, lastModified := "", time.Time{}
+	if h.Conditional && h.Validators != nil {
+		etag, lastModified = h.Validators(params)
+		switch checkConditions(r, etag, lastModified) {
+		case http.StatusNotModified:
+			setValidators(w, encodedETag(etag, enc), lastModified)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		case http.StatusPreconditionFailed:
+			h.errorResponse(w, errors.New("precondition failed"), http.StatusPreconditionFailed)
+			return
+		}
 	}
 
+	if h.Sequence != nil {
+		seqRes, err := h.Sequence.next()
+		if err != nil {
+			h.errorResponse(w, err, http.StatusInternalServerError)
+			return
+		}
+		h = h.withResponse(seqRes)
+	}
 	if h.ResponseFn == nil {
 		h.ResponseFn = defaultResponseFn
 	}
 	res, err := h.ResponseFn(h.RequestBody, params, r.URL.Query())
+	if h.Scenario != nil && h.NewState != "" {
+		h.Scenario.SetState(h.NewState)
+	}
 	if err != nil {
-		h.errorResponse(w, err, http.StatusBadRequest)
+		code := http.StatusBadRequest
+		var se *StatusError
+		if errors.As(err, &se) {
+			code = se.StatusCode
+		}
+		h.errorResponse(w, err, code)
 		return
 	}
-	if res != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(h.ResponseCode)
-		json.NewEncoder(w).Encode(res)
+	if resp, ok := res.(Response); ok {
+		h = h.withResponse(resp)
+		res = resp.Body
+	}
+	for k, vs := range h.ResponseHeader {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	if res == nil {
+		if fault != NoFault {
+			writeFault(w, fault, h.statusCode(), nil)
+		} else if h.ResponseCode != 0 {
+			w.WriteHeader(h.ResponseCode)
+		}
+		return
+	}
+	b, err := resCodec.Encode(res)
+	if err != nil {
+		h.errorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", resCodec.ContentType())
+	if h.Conditional && (r.Method == http.MethodGet || r.Method == http.MethodHead) && h.statusCode()/100 == 2 {
+		if etag == "" {
+			etag = generateETag(b)
+		}
+		setValidators(w, encodedETag(etag, enc), lastModified)
+		if h.Validators == nil && etagMatches(r.Header.Get("If-None-Match"), etag, true) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 	}
+	if enc != "" {
+		if rec, ok := w.(uncompressedRecorder); ok {
+			rec.recordUncompressed(b)
+		}
+		compressed, err := h.Compression.compress(b, enc)
+		if err != nil {
+			h.errorResponse(w, err, http.StatusInternalServerError)
+			return
+		}
+		b = compressed
+		w.Header().Set("Content-Encoding", enc)
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	if fault != NoFault {
+		writeFault(w, fault, h.statusCode(), b)
+		return
+	}
+	w.WriteHeader(h.ResponseCode)
+	writeBody(r.Context(), h.Clock, w, b, h.Latency.total()-now(h.Clock).Sub(start))
+}
+
+func (h JSONHandler) statusCode() int {
+	if h.ResponseCode == 0 {
+		return http.StatusOK
+	}
+	return h.ResponseCode
+}
+
+// withResponse returns a copy of h responding with res.
+func (h JSONHandler) withResponse(res Response) JSONHandler {
+	if res.StatusCode != 0 {
+		h.ResponseCode = res.StatusCode
+	}
+	header := h.ResponseHeader.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	for k, vs := range res.Header {
+		header[k] = vs
+	}
+	h.ResponseHeader = header
+	h.ResponseFn = func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+		return res.Body, nil
+	}
+	return h
+}
+
+// StatusError is an error returned by JSONHandler.ResponseFn to respond with
+// an HTTP response code other than 400.
+type StatusError struct {
+	StatusCode int
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns e.Err.
+func (e *StatusError) Unwrap() error {
+	return e.Err
 }
 
 type errorResponse struct {
@@ -154,6 +534,15 @@ func (h JSONHandler) errorResponse(w http.ResponseWriter, err error, statusCode
 	json.NewEncoder(w).Encode(errRes)
 }
 
+// encodeJSON encodes v as json.Encoder does.
+func encodeJSON(v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
 func defaultResponseFn(res interface{}, _ []string, _ url.Values) (interface{}, error) {
 	return res, nil
 }
@@ -163,18 +552,36 @@ func defaultResponseFn(res interface{}, _ []string, _ url.Values) (interface{},
 type MultipleHandler struct {
 	// ErrResponseFn specifies how to return an error response.
 	ErrResponseFn func(http.ResponseWriter, error, int)
+	// Journal records the served HTTP requests if it is not nil.
+	Journal *Journal
+	// Scenarios is the set of scenarios used by the JSONHandlers, so that their
+	// states can be inspected and reset.  It is set by LoadStubs() and
+	// LoadWireMock().
+	Scenarios *Scenarios
+	// Latency is used for the JSONHandlers whose Latency is nil.
+	Latency *Latency
+	// RateLimit limits the rate of all the requests, in addition to
+	// JSONHandler.RateLimit.
+	RateLimit *RateLimiter
+	// Clock is used for the JSONHandlers whose Clock is nil, and for the
+	// timestamps in Journal.  If nil, the system clock is used.
+	Clock Clock
+	// Fallback serves the HTTP requests that do not match any JSONHandler.
+	// If nil, a 404 error response is returned.  NewReverseProxy() can be used
+	// to override only some endpoints of a real server.
+	Fallback http.Handler
 
 	handlers []JSONHandler
 }
 
 // NewMultipleHandler creates an instance of MultipleHandler.
 // The JSONHandler argument specifies that the Method and PathFmt fields must
-// not be empty strings.
+// not be empty strings.  PathFmt may be empty if PathRegexp is specified.
 // Requests are matched in the order of the array.
 func NewMultipleHandler(hs []JSONHandler) *MultipleHandler {
 	handlers := make([]JSONHandler, 0, len(hs))
 	for _, h := range hs {
-		if h.Method != "" && h.PathFmt != "" {
+		if h.routable() {
 			handlers = append(handlers, h)
 		}
 	}
@@ -185,9 +592,9 @@ func NewMultipleHandler(hs []JSONHandler) *MultipleHandler {
 
 // AddHandler adds a JSONHandler to mock.
 // The JSONHandler argument specifies that the Method and PathFmt fields must
-// not be empty strings.
+// not be empty strings.  PathFmt may be empty if PathRegexp is specified.
 func (h *MultipleHandler) AddHandler(handler JSONHandler) {
-	if handler.Method == "" || handler.PathFmt == "" {
+	if !handler.routable() {
 		return
 	}
 	if h.handlers == nil {
@@ -199,19 +606,132 @@ func (h *MultipleHandler) AddHandler(handler JSONHandler) {
 
 // ServeHTTP is a method to implement http.Handler.
 func (h MultipleHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.serveJournaled(w, r, "", h.route(r), h.serveHTTP)
+}
+
+// route is the result of matching a request against the JSONHandlers of a
+// MultipleHandler.
+type route struct {
+	// handler is the first matching JSONHandler if ok is true.
+	handler JSONHandler
+	ok      bool
+	err     error
+	// maxBodySize is MaxRequestBodySize of handler, or of the first
+	// JSONHandler bound to the HTTP method and the URL path if no
+	// JSONHandler matches.
+	maxBodySize int64
+}
+
+// route matches the request against the JSONHandlers in order.
+func (h MultipleHandler) route(r *http.Request) route {
+	rt := route{}
 	for _, handler := range h.handlers {
-		if handler.Method == r.Method {
-			ok, err := path.Match(handler.PathFmt, r.URL.Path)
-			if err != nil {
-				h.errorResponse(w, err, http.StatusInternalServerError)
-			}
-			if ok {
-				handler.ServeHTTP(w, r)
-			}
+		if ok, _ := handler.matchRoute(r); ok && rt.maxBodySize == 0 {
+			rt.maxBodySize = handler.MaxRequestBodySize
+		}
+		ok, err := handler.match(r)
+		if err != nil {
+			return route{err: err, maxBodySize: rt.maxBodySize}
+		}
+		if ok {
+			return route{handler: handler, ok: true, maxBodySize: handler.MaxRequestBodySize}
+		}
+	}
+	return rt
+}
+
+// serveJournaled serves the request routed to rt with serve, which reports
+// whether the request matched any JSONHandler, and records it in the journal.
+// The recorded body is limited by rt.maxBodySize.
+func (h MultipleHandler) serveJournaled(w http.ResponseWriter, r *http.Request, chaos ChaosEvent, rt route, serve func(http.ResponseWriter, *http.Request, route) bool) {
+	if h.Journal == nil {
+		serve(w, r, rt)
+		return
+	}
+
+	body, truncated, _ := peekBodyLimit(r, rt.maxBodySize)
+	e := JournalEntry{
+		Time:          now(h.Clock),
+		Method:        r.Method,
+		Host:          r.Host,
+		URL:           r.URL.RequestURI(),
+		Header:        r.Header.Clone(),
+		Body:          string(body),
+		BodyTruncated: truncated,
+		Chaos:         chaos,
+	}
+	sw := &statusWriter{ResponseWriter: w, maxBody: h.Journal.maxResponseBodySize()}
+	e.Matched = serve(sw, r, rt)
+	e.Duration = now(h.Clock).Sub(e.Time)
+	e.StatusCode = sw.statusCode
+	if e.StatusCode == 0 && !sw.hijacked {
+		e.StatusCode = http.StatusOK
+	}
+	if !truncated && e.StatusCode != http.StatusRequestEntityTooLarge && mediaType(r.Header.Get("Content-Type")) == "multipart/form-data" {
+		e.Parts, _ = parseMultipart(r.Header.Get("Content-Type"), bytes.NewReader(body))
+	}
+	e.ResponseHeader = w.Header().Clone()
+	e.ResponseBody = sw.body.String()
+	e.ResponseBodyTruncated = sw.truncated
+	e.WebSocket = sw.webSocket
+	e.GraphQL = sw.graphQL
+	h.Journal.record(e)
+}
+
+// serveHTTP dispatches the request to the JSONHandler chosen by route() and
+// reports whether there is such a JSONHandler.
+func (h MultipleHandler) serveHTTP(w http.ResponseWriter, r *http.Request, rt route) bool {
+	if h.RateLimit.limit(w, r, h.Clock) {
+		h.errorResponse(w, errRateLimited, http.StatusTooManyRequests)
+		return rt.ok
+	}
+
+	if rt.err != nil {
+		h.errorResponse(w, rt.err, http.StatusInternalServerError)
+		return false
+	}
+	if rt.ok {
+		handler := rt.handler
+		if handler.Latency == nil {
+			handler.Latency = h.Latency
+		}
+		if handler.Clock == nil {
+			handler.Clock = h.Clock
+		}
+		params, err := handler.checkPath(r.URL.Path)
+		if err != nil {
+			handler.errorResponse(w, err, http.StatusNotFound)
+			return true
 		}
+		handler.serveMatched(w, r, params)
+		return true
 	}
 
+	if h.Fallback != nil {
+		h.Fallback.ServeHTTP(w, r)
+		return false
+	}
 	h.errorResponse(w, errors.New("not found"), http.StatusNotFound)
+	return false
+}
+
+// NewReverseProxy creates an HTTP handler that forwards requests to the target
+// URL.  It is intended to be used as MultipleHandler.Fallback.
+func NewReverseProxy(target string) (http.Handler, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid target URL: %v", target)
+	}
+	p := httputil.NewSingleHostReverseProxy(u)
+	director := p.Director
+	p.Director = func(r *http.Request) {
+		director(r)
+		r.Host = u.Host
+	}
+	return p, nil
 }
 
 func (h MultipleHandler) errorResponse(w http.ResponseWriter, err error, statusCode int) {
@@ -222,6 +742,7 @@ func (h MultipleHandler) errorResponse(w http.ResponseWriter, err error, statusC
 
 	if len(h.handlers) != 0 {
 		h.handlers[0].errorResponse(w, err, statusCode)
+		return
 	}
 
 	if err == nil {
@@ -9,6 +9,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"reflect"
+	"regexp"
 	"testing"
 )
 
@@ -253,6 +254,38 @@ func TestJSONHandler_checkPath_parsedParams(t *testing.T) {
 	}
 }
 
+func TestJSONHandler_checkPath_pathRegexp(t *testing.T) {
+	cases := []struct {
+		input string
+		want  []string
+		err   bool
+	}{
+		{input: "/groups/g1/users/1", want: []string{"g1", "1"}, err: false},
+		{input: "/groups/g1/users/a", want: nil, err: true},
+		{input: "/groups/g1/users/1/", want: nil, err: true},
+		{input: "/prefix/groups/g1/users/1", want: nil, err: true},
+	}
+
+	h := JSONHandler{
+		PathFmt:    "/never/used",
+		PathRegexp: regexp.MustCompile("/groups/([^/]+)/users/([0-9]+)"),
+	}
+	for _, tt := range cases {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := h.checkPath(tt.input)
+			if !tt.err && err != nil {
+				t.Fatalf("should not be error, but: %v", err)
+			}
+			if tt.err && err == nil {
+				t.Fatalf("should be error, but not")
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("want %v, but got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
 func TestJSONHandler_checkMethod(t *testing.T) {
 	type input struct {
 		wantMethod string
@@ -699,3 +732,62 @@ func TestMultipleHandler_ServeHTTP_unmatched(t *testing.T) {
 		})
 	}
 }
+
+func TestMultipleHandler_ServeHTTP_fallback(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("upstream " + r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	fallback, err := NewReverseProxy(upstream.URL)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	h := NewMultipleHandler([]JSONHandler{
+		{
+			Method:       "GET",
+			PathFmt:      "/users/*",
+			ResponseCode: 200,
+			ResponseFn: func(_ interface{}, pParams []string, _ url.Values) (interface{}, error) {
+				return map[string]interface{}{"fake": pParams[0]}, nil
+			},
+		},
+	})
+	h.Fallback = fallback
+	h.Journal = &Journal{}
+
+	cases := []struct {
+		path string
+		want string
+	}{
+		{path: "/users/1", want: `{"fake":"1"}` + "\n"},
+		{path: "/groups/1", want: "upstream /groups/1"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost"+tt.path, nil))
+			if w.Code != 200 {
+				t.Fatalf("want 200, but got %v", w.Code)
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Fatalf("want %v, but got %v", tt.want, got)
+			}
+		})
+	}
+
+	if got := h.Journal.Unmatched(); len(got) != 1 || got[0].URL != "/groups/1" {
+		t.Fatalf("want the fallback request to be unmatched, but got %v", got)
+	}
+}
+
+func TestNewReverseProxy_invalidTarget(t *testing.T) {
+	cases := []string{"", "/relative", "://"}
+	for _, tt := range cases {
+		t.Run(tt, func(t *testing.T) {
+			if _, err := NewReverseProxy(tt); err == nil {
+				t.Fatalf("should be error, but not")
+			}
+		})
+	}
+}
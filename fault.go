@@ -0,0 +1,145 @@
+package fakehttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// Fault is a network-level failure injected into a response.
+type Fault int
+
+const (
+	// NoFault responds normally.
+	NoFault Fault = iota
+	// FaultConnectionReset resets the connection before responding.
+	FaultConnectionReset
+	// FaultHeadersThenReset sends the response header, then resets the
+	// connection.
+	FaultHeadersThenReset
+	// FaultTruncatedBody sends the first half of the response body, then
+	// closes the connection.
+	FaultTruncatedBody
+	// FaultMalformedJSON sends the first half of the response body as a
+	// complete response, which is invalid JSON.
+	FaultMalformedJSON
+	// FaultWrongContentLength sends the response body with a Content-Length
+	// larger than the body, then closes the connection.
+	FaultWrongContentLength
+)
+
+var faultNames = map[Fault]string{
+	NoFault:                 "none",
+	FaultConnectionReset:    "connectionReset",
+	FaultHeadersThenReset:   "headersThenReset",
+	FaultTruncatedBody:      "truncatedBody",
+	FaultMalformedJSON:      "malformedJSON",
+	FaultWrongContentLength: "wrongContentLength",
+}
+
+// String returns the name of the fault, which is also used in stub files.
+func (f Fault) String() string {
+	if name, ok := faultNames[f]; ok {
+		return name
+	}
+	return "Fault(" + strconv.Itoa(int(f)) + ")"
+}
+
+// ParseFault returns the Fault with the name returned by Fault.String().
+func ParseFault(name string) (Fault, error) {
+	for f, n := range faultNames {
+		if n == name {
+			return f, nil
+		}
+	}
+	return NoFault, fmt.Errorf("unknown fault: %v", name)
+}
+
+// FaultInjector injects a fault into responses at a rate.
+// It is safe for concurrent use.
+type FaultInjector struct {
+	// Fault is the fault to inject.
+	Fault Fault
+	// Rate is the probability in [0, 1] to inject the fault into a response.
+	Rate float64
+
+	rnd *lockedRand
+}
+
+// NewFaultInjector creates an instance of FaultInjector.  The random numbers
+// are generated from the seed, so that the injected faults are reproducible.
+// Specify the rate 1 to inject the fault into every response.
+func NewFaultInjector(fault Fault, rate float64, seed int64) *FaultInjector {
+	return &FaultInjector{Fault: fault, Rate: rate, rnd: newLockedRand(seed)}
+}
+
+func (f *FaultInjector) next() Fault {
+	if f == nil || f.Rate <= 0 {
+		return NoFault
+	}
+	if f.Rate >= 1 || f.rnd == nil {
+		return f.Fault
+	}
+	if f.rnd.Float64() < f.Rate {
+		return f.Fault
+	}
+	return NoFault
+}
+
+// writeFault writes the response with the fault.  The header of w must be set
+// in advance.
+func writeFault(w http.ResponseWriter, fault Fault, statusCode int, body []byte) {
+	switch fault {
+	case FaultConnectionReset:
+		abort(w, true)
+	case FaultHeadersThenReset:
+		w.WriteHeader(statusCode)
+		flush(w)
+		abort(w, true)
+	case FaultTruncatedBody:
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(statusCode)
+		w.Write(body[:len(body)/2])
+		flush(w)
+		abort(w, false)
+	case FaultMalformedJSON:
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)/2))
+		w.WriteHeader(statusCode)
+		w.Write(body[:len(body)/2])
+	case FaultWrongContentLength:
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)+len(body)/2+1))
+		w.WriteHeader(statusCode)
+		w.Write(body)
+		flush(w)
+		abort(w, false)
+	default:
+		w.WriteHeader(statusCode)
+		w.Write(body)
+	}
+}
+
+func flush(w http.ResponseWriter) {
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// abort closes the connection.  If reset is true, the connection is reset
+// rather than closed gracefully where possible.  If w does not support
+// hijacking, the handler panics with http.ErrAbortHandler, which makes the
+// server abort the response.
+func abort(w http.ResponseWriter, reset bool) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tc, ok := conn.(*net.TCPConn); ok && reset {
+		tc.SetLinger(0)
+	}
+	conn.Close()
+}
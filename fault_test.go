@@ -0,0 +1,122 @@
+package fakehttp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestJSONHandler_ServeHTTP_fault(t *testing.T) {
+	cases := []struct {
+		fault      Fault
+		fail       bool
+		invalidRes bool
+	}{
+		{fault: NoFault},
+		{fault: FaultConnectionReset, fail: true},
+		{fault: FaultHeadersThenReset, fail: true},
+		{fault: FaultTruncatedBody, fail: true},
+		{fault: FaultMalformedJSON, invalidRes: true},
+		{fault: FaultWrongContentLength, fail: true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.fault.String(), func(t *testing.T) {
+			h := JSONHandler{
+				Method:       "GET",
+				PathFmt:      "/users",
+				ResponseCode: 200,
+				Fault:        NewFaultInjector(tt.fault, 1, 0),
+				ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+					return map[string]string{"name": "test-user"}, nil
+				},
+			}
+			s := httptest.NewServer(h)
+			defer s.Close()
+
+			b, err := get(s.URL + "/users")
+			if tt.fail {
+				if err == nil {
+					t.Fatalf("should be error, but got %q", b)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("should not be error, but: %v", err)
+			}
+			if got := json.Valid(b); got == tt.invalidRes {
+				t.Fatalf("unexpected body: %q", b)
+			}
+		})
+	}
+}
+
+// get returns the response body, or an error if the connection fails before
+// the whole body is read.
+func get(url string) ([]byte, error) {
+	res, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	return ioutil.ReadAll(res.Body)
+}
+
+func TestFaultInjector_rate(t *testing.T) {
+	f1 := NewFaultInjector(FaultConnectionReset, 0.3, 42)
+	f2 := NewFaultInjector(FaultConnectionReset, 0.3, 42)
+	injected := 0
+	for i := 0; i < 1000; i++ {
+		got := f1.next()
+		if want := f2.next(); got != want {
+			t.Fatalf("want reproducible %v, but got %v", want, got)
+		}
+		if got != NoFault {
+			injected++
+		}
+	}
+	if injected < 200 || injected > 400 {
+		t.Fatalf("want about 300 faults, but got %v", injected)
+	}
+
+	if got := (*FaultInjector)(nil).next(); got != NoFault {
+		t.Fatalf("want %v, but got %v", NoFault, got)
+	}
+}
+
+func TestParseFault(t *testing.T) {
+	for f := range faultNames {
+		got, err := ParseFault(f.String())
+		if err != nil {
+			t.Fatalf("should not be error, but: %v", err)
+		}
+		if got != f {
+			t.Fatalf("want %v, but got %v", f, got)
+		}
+	}
+	if _, err := ParseFault("unknown"); err == nil {
+		t.Fatalf("should be error, but not")
+	}
+}
+
+func TestStub_JSONHandler_fault(t *testing.T) {
+	s := Stub{
+		Request: StubRequest{Method: "GET", Path: "/users"},
+		Fault:   "malformedJSON",
+	}
+	h, err := s.JSONHandler("", nil)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if h.Fault == nil || h.Fault.Fault != FaultMalformedJSON || h.Fault.Rate != 1 {
+		t.Fatalf("unexpected fault: %+v", h.Fault)
+	}
+
+	s.Fault = "unknown"
+	if _, err := s.JSONHandler("", nil); err == nil {
+		t.Fatalf("should be error, but not")
+	}
+}
@@ -0,0 +1,201 @@
+package fakehttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+)
+
+// GraphQLRequest is a GraphQL operation POSTed by the client.
+type GraphQLRequest struct {
+	// Query is the GraphQL document.
+	Query string `json:"query"`
+	// OperationName is the name of the operation to execute.  If the client
+	// omits it, it is taken from the first operation in Query.
+	OperationName string `json:"operationName,omitempty"`
+	// Variables are the variables of the operation.
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+// GraphQLError is an error in a GraphQL response.
+type GraphQLError struct {
+	// Message is the description of the error.
+	Message string `json:"message"`
+	// Path is the path of the response field that caused the error.
+	Path []interface{} `json:"path,omitempty"`
+	// Extensions are additional information such as an error code.
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+// GraphQLOperation is a stub of a GraphQL operation.
+type GraphQLOperation struct {
+	// OperationName is the name of the operation to match.  Skip the check if
+	// it is an empty string.
+	OperationName string
+	// Variables are matched if they are JSON equivalent to the variables of
+	// the request.  Skip the check if it is nil.
+	Variables map[string]interface{}
+	// Data is the data of the response.
+	Data interface{}
+	// Errors are the errors of the response.
+	Errors []GraphQLError
+	// ResponseFn is the function to return the data of the response instead
+	// of Data if it is not nil.  The argument is the request.  If an error is
+	// returned, it is added to Errors.
+	ResponseFn func(GraphQLRequest) (interface{}, error)
+}
+
+// graphQLResponse is the envelope of a GraphQL response.
+type graphQLResponse struct {
+	Data   interface{}    `json:"data"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
+
+// GraphQLHandler is a mock of a GraphQL endpoint, which serves every operation
+// POSTed to a single URL path.  The operations are matched in the order added,
+// and the request is recorded in JournalEntry.GraphQL when served by
+// MultipleHandler.  Operations can be added while serving requests.
+type GraphQLHandler struct {
+	// PathFmt is a pattern of URL paths to bind a handler to.  See
+	// JSONHandler.PathFmt.
+	PathFmt string
+	// Method is an HTTP request method.  Skip the HTTP method check if it is an
+	// empty string.
+	Method string
+	// Matchers are additional conditions that the HTTP request must satisfy.
+	Matchers []Matcher
+	// ErrResponseFn specifies how to return an error response if the URL path
+	// or the HTTP method does not match.  See JSONHandler.ErrResponseFn.
+	ErrResponseFn func(http.ResponseWriter, error, int)
+
+	mu         sync.RWMutex
+	operations []GraphQLOperation
+}
+
+// NewGraphQLHandler creates an instance of GraphQLHandler bound to POST
+// /graphql.  Operations are matched in the order of the array.
+func NewGraphQLHandler(ops []GraphQLOperation) *GraphQLHandler {
+	return &GraphQLHandler{
+		PathFmt:    "/graphql",
+		Method:     http.MethodPost,
+		operations: append([]GraphQLOperation{}, ops...),
+	}
+}
+
+// AddOperation adds a GraphQLOperation to mock.
+func (h *GraphQLHandler) AddOperation(op GraphQLOperation) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.operations = append(h.operations, op)
+}
+
+// jsonHandler returns the JSONHandler that matches the same HTTP requests as
+// h, and serves them with h.
+func (h *GraphQLHandler) jsonHandler() JSONHandler {
+	return JSONHandler{
+		PathFmt:       h.PathFmt,
+		Method:        h.Method,
+		Matchers:      h.Matchers,
+		ErrResponseFn: h.ErrResponseFn,
+		serve: func(w http.ResponseWriter, r *http.Request, _ Clock) {
+			h.execute(w, r)
+		},
+	}
+}
+
+// ServeHTTP is a method to implement http.Handler.
+func (h *GraphQLHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.jsonHandler().ServeHTTP(w, r)
+}
+
+// graphQLOperationName matches the name of the first operation in a query.
+var graphQLOperationName = regexp.MustCompile(`(?:query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+// execute responds to the GraphQL request with the first matching operation.
+func (h *GraphQLHandler) execute(w http.ResponseWriter, r *http.Request) {
+	var req GraphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeGraphQL(w, http.StatusBadRequest, graphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}})
+		return
+	}
+	if req.Query == "" {
+		writeGraphQL(w, http.StatusBadRequest, graphQLResponse{Errors: []GraphQLError{{Message: "missing query"}}})
+		return
+	}
+	if req.OperationName == "" {
+		if m := graphQLOperationName.FindStringSubmatch(req.Query); m != nil {
+			req.OperationName = m[1]
+		}
+	}
+	if rec, ok := w.(graphQLRecorder); ok {
+		rec.recordGraphQL(req)
+	}
+
+	op, err := h.match(req)
+	if err != nil {
+		writeGraphQL(w, http.StatusNotFound, graphQLResponse{Errors: []GraphQLError{{Message: err.Error()}}})
+		return
+	}
+	res := graphQLResponse{
+		Data:   op.Data,
+		Errors: append([]GraphQLError{}, op.Errors...),
+	}
+	if op.ResponseFn != nil {
+		data, err := op.ResponseFn(req)
+		res.Data = data
+		if err != nil {
+			res.Errors = append(res.Errors, GraphQLError{Message: err.Error()})
+		}
+	}
+	writeGraphQL(w, http.StatusOK, res)
+}
+
+// match returns the first operation matching the request.
+func (h *GraphQLHandler) match(req GraphQLRequest) (GraphQLOperation, error) {
+	h.mu.RLock()
+	ops := h.operations
+	h.mu.RUnlock()
+	for _, op := range ops {
+		if op.OperationName != "" && op.OperationName != req.OperationName {
+			continue
+		}
+		if op.Variables != nil {
+			want, err := normalizeJSON(op.Variables)
+			if err != nil {
+				return GraphQLOperation{}, err
+			}
+			got, err := json.Marshal(req.Variables)
+			if err != nil || !equalJSON(want, got) {
+				continue
+			}
+		}
+		return op, nil
+	}
+	if req.OperationName == "" {
+		return GraphQLOperation{}, errors.New("no operation matched the anonymous operation")
+	}
+	return GraphQLOperation{}, fmt.Errorf("no operation matched: %v", req.OperationName)
+}
+
+func writeGraphQL(w http.ResponseWriter, statusCode int, res graphQLResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(res)
+}
+
+// AddGraphQLHandler adds a GraphQLHandler to mock.  Operations added to the
+// GraphQLHandler later are also served.
+// The GraphQLHandler argument specifies that the Method and PathFmt fields
+// must not be empty strings.
+func (h *MultipleHandler) AddGraphQLHandler(handler *GraphQLHandler) {
+	h.AddHandler(handler.jsonHandler())
+}
+
+// graphQLRecorder is implemented by the http.ResponseWriter that records the
+// GraphQL request in the journal.
+type graphQLRecorder interface {
+	recordGraphQL(GraphQLRequest)
+}
@@ -0,0 +1,125 @@
+package fakehttp
+
+import (
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestGraphQLHandler_ServeHTTP(t *testing.T) {
+	g := NewGraphQLHandler([]GraphQLOperation{
+		{
+			OperationName: "GetUser",
+			Variables:     map[string]interface{}{"id": 1},
+			Data:          map[string]interface{}{"user": map[string]string{"name": "a"}},
+		},
+		{
+			OperationName: "GetUser",
+			Data:          map[string]interface{}{"user": nil},
+			Errors:        []GraphQLError{{Message: "not found", Path: []interface{}{"user"}}},
+		},
+	})
+	g.AddOperation(GraphQLOperation{
+		OperationName: "CreateUser",
+		ResponseFn: func(req GraphQLRequest) (interface{}, error) {
+			if req.Variables["name"] == "" {
+				return nil, errors.New("name is required")
+			}
+			return map[string]interface{}{"createUser": req.Variables}, nil
+		},
+	})
+
+	cases := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{name: "variables", body: `{"query":"query GetUser($id: ID!) { user(id: $id) { name } }","operationName":"GetUser","variables":{"id":1}}`, wantCode: 200, wantBody: `{"data":{"user":{"name":"a"}}}`},
+		{name: "other_variables", body: `{"query":"query GetUser($id: ID!) { user(id: $id) { name } }","variables":{"id":2}}`, wantCode: 200, wantBody: `{"data":{"user":null},"errors":[{"message":"not found","path":["user"]}]}`},
+		{name: "response_fn", body: `{"query":"mutation CreateUser($name: String!) { createUser(name: $name) { name } }","variables":{"name":"b"}}`, wantCode: 200, wantBody: `{"data":{"createUser":{"name":"b"}}}`},
+		{name: "response_fn_error", body: `{"query":"mutation CreateUser { createUser { name } }","variables":{"name":""}}`, wantCode: 200, wantBody: `{"data":null,"errors":[{"message":"name is required"}]}`},
+		{name: "unknown_operation", body: `{"query":"query ListUsers { users { name } }"}`, wantCode: 404, wantBody: `{"data":null,"errors":[{"message":"no operation matched: ListUsers"}]}`},
+		{name: "missing_query", body: `{}`, wantCode: 400, wantBody: `{"data":null,"errors":[{"message":"missing query"}]}`},
+		{name: "invalid_json", body: `{`, wantCode: 400},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "http://localhost/graphql", strings.NewReader(tt.body))
+			r.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, r)
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("want %v, but got %v: %v", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantBody == "" {
+				return
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.wantBody {
+				t.Fatalf("want %v, but got %v", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestMultipleHandler_AddGraphQLHandler(t *testing.T) {
+	h := NewMultipleHandler([]JSONHandler{{Method: "GET", PathFmt: "/health", ResponseCode: 200}})
+	h.Journal = &Journal{}
+	g := NewGraphQLHandler(nil)
+	h.AddGraphQLHandler(g)
+	// Operations added after the registration are served too.
+	g.AddOperation(GraphQLOperation{OperationName: "Viewer", Data: map[string]string{"login": "a"}})
+
+	r := httptest.NewRequest("POST", "http://localhost/graphql", strings.NewReader(`{"query":"{ viewer { login } }","operationName":"Viewer"}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Fatalf("want 200, but got %v: %v", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/graphql", nil))
+	if w.Code != 404 {
+		t.Fatalf("want 404, but got %v", w.Code)
+	}
+
+	entries := h.Journal.Entries()
+	if len(entries) != 2 {
+		t.Fatalf("want 2 entries, but got %v", len(entries))
+	}
+	want := &GraphQLRequest{Query: "{ viewer { login } }", OperationName: "Viewer"}
+	if got := entries[0].GraphQL; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %+v, but got %+v", want, got)
+	}
+	if entries[1].GraphQL != nil || entries[1].Matched {
+		t.Fatalf("unexpected entry: %+v", entries[1])
+	}
+}
+
+func TestGraphQLHandler_AddOperation_concurrent(t *testing.T) {
+	g := NewGraphQLHandler([]GraphQLOperation{{OperationName: "Viewer", Data: map[string]string{"login": "a"}}})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			g.AddOperation(GraphQLOperation{OperationName: "CreateUser"})
+		}()
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest("POST", "http://localhost/graphql", strings.NewReader(`{"query":"query Viewer { viewer { login } }"}`))
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, r)
+			if w.Code != 200 {
+				t.Errorf("want 200, but got %v: %v", w.Code, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+}
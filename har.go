@@ -0,0 +1,285 @@
+package fakehttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+)
+
+// harVersion is the version of the HAR format written by Journal.WriteHAR().
+const harVersion = "1.2"
+
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// LoadHAR creates a MultipleHandler replaying the responses captured in a HAR
+// file.  Requests are matched on the method, the URL path, the query
+// parameters and the body, ignoring the scheme and the host.  If the same
+// request is captured more than once, the first response is replayed.
+//
+// Only JSON responses can be replayed.  The returned slice reports the
+// entries skipped for that reason.
+func LoadHAR(filename string) (*MultipleHandler, []string, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := harFile{}
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, nil, fmt.Errorf("%v: %w", filename, err)
+	}
+
+	handlers := []JSONHandler{}
+	skipped := []string{}
+	for i, e := range f.Log.Entries {
+		h, err := e.jsonHandler()
+		if err != nil {
+			skipped = append(skipped, fmt.Sprintf("entries[%v] %v %v: %v", i, e.Request.Method, e.Request.URL, err))
+			continue
+		}
+		handlers = append(handlers, h)
+	}
+	return NewMultipleHandler(handlers), skipped, nil
+}
+
+func (e harEntry) jsonHandler() (JSONHandler, error) {
+	u, err := url.Parse(e.Request.URL)
+	if err != nil {
+		return JSONHandler{}, err
+	}
+	if e.Request.Method == "" || u.Path == "" {
+		return JSONHandler{}, errors.New("method and URL must not be empty")
+	}
+	if e.Response.Status < 100 {
+		return JSONHandler{}, fmt.Errorf("invalid status: %v", e.Response.Status)
+	}
+
+	h := JSONHandler{
+		Method:         e.Request.Method,
+		PathFmt:        escapePathFmt(u.Path),
+		ResponseCode:   e.Response.Status,
+		ResponseHeader: http.Header{},
+		Matchers:       []Matcher{},
+	}
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			h.Matchers = append(h.Matchers, QueryMatcher(k, v))
+		}
+	}
+	if e.Request.PostData != nil && e.Request.PostData.Text != "" {
+		text := e.Request.PostData.Text
+		if e.Request.PostData.Encoding == "base64" {
+			b, err := base64.StdEncoding.DecodeString(text)
+			if err != nil {
+				return JSONHandler{}, err
+			}
+			text = string(b)
+		}
+		h.Matchers = append(h.Matchers, harBodyMatcher(text))
+	}
+
+	for _, nv := range e.Response.Headers {
+		switch http.CanonicalHeaderKey(nv.Name) {
+		case "Content-Type", "Content-Length", "Content-Encoding", "Transfer-Encoding", "Date":
+			continue
+		}
+		h.ResponseHeader.Add(nv.Name, nv.Value)
+	}
+
+	text := []byte(e.Response.Content.Text)
+	if e.Response.Content.Encoding == "base64" {
+		if text, err = base64.StdEncoding.DecodeString(e.Response.Content.Text); err != nil {
+			return JSONHandler{}, err
+		}
+	}
+	var body interface{}
+	if len(bytes.TrimSpace(text)) != 0 {
+		if !json.Valid(text) {
+			return JSONHandler{}, fmt.Errorf("response is not JSON: %v", e.Response.Content.MimeType)
+		}
+		body = json.RawMessage(text)
+	}
+	h.ResponseFn = func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+		return body, nil
+	}
+	return h, nil
+}
+
+// harBodyMatcher matches the request body equivalent to the JSON text, or equal
+// to the text if it is not JSON.
+func harBodyMatcher(text string) Matcher {
+	var v interface{}
+	if err := json.Unmarshal([]byte(text), &v); err == nil {
+		return BodyJSONMatcher(v)
+	}
+	return func(r *http.Request) bool {
+		body, err := peekMatchedBody(r)
+		return err == nil && string(body) == text
+	}
+}
+
+// WriteHAR writes the recorded entries in the HAR format, so that the traffic
+// can be inspected with browser developer tools.
+func (j *Journal) WriteHAR(w io.Writer) error {
+	f := harFile{
+		Log: harLog{
+			Version: harVersion,
+			Creator: harCreator{Name: "fakehttp", Version: harVersion},
+			Entries: []harEntry{},
+		},
+	}
+	for _, e := range j.Entries() {
+		f.Log.Entries = append(f.Log.Entries, e.harEntry())
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+func (e JournalEntry) harEntry() harEntry {
+	u := url.URL{Scheme: "http", Host: e.Host}
+	if ref, err := url.Parse(e.URL); err == nil {
+		u = *u.ResolveReference(ref)
+	}
+	ms := float64(e.Duration) / float64(time.Millisecond)
+
+	ret := harEntry{
+		StartedDateTime: e.Time.Format(time.RFC3339Nano),
+		Time:            ms,
+		Request: harRequest{
+			Method:      e.Method,
+			URL:         u.String(),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(e.Header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(e.Body),
+		},
+		Response: harResponse{
+			Status:      e.StatusCode,
+			StatusText:  http.StatusText(e.StatusCode),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(e.ResponseHeader),
+			Content: harContent{
+				Size:     len(e.ResponseBody),
+				MimeType: e.ResponseHeader.Get("Content-Type"),
+			},
+			HeadersSize: -1,
+			BodySize:    len(e.ResponseBody),
+		},
+		Timings: harTimings{Wait: ms},
+	}
+	ret.Response.Content.Text, ret.Response.Content.Encoding = harText(e.ResponseBody)
+	for k, vs := range u.Query() {
+		for _, v := range vs {
+			ret.Request.QueryString = append(ret.Request.QueryString, harNameValue{Name: k, Value: v})
+		}
+	}
+	if e.Body != "" {
+		ret.Request.PostData = &harPostData{
+			MimeType: e.Header.Get("Content-Type"),
+		}
+		ret.Request.PostData.Text, ret.Request.PostData.Encoding = harText(e.Body)
+	}
+	return ret
+}
+
+// harText returns the body as the text of HAR and its encoding.  A body which
+// is not valid UTF-8, such as a compressed one, is encoded in base64 since it
+// cannot be represented in JSON as is.
+func harText(body string) (string, string) {
+	if utf8.ValidString(body) {
+		return body, ""
+	}
+	return base64.StdEncoding.EncodeToString([]byte(body)), "base64"
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	ret := []harNameValue{}
+	for k, vs := range header {
+		for _, v := range vs {
+			ret = append(ret, harNameValue{Name: k, Value: v})
+		}
+	}
+	return ret
+}
@@ -0,0 +1,170 @@
+package fakehttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadHAR(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fakehttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile(t, dir, "capture.har", `{
+  "log": {
+    "version": "1.2",
+    "entries": [
+      {
+        "request": {"method": "GET", "url": "https://example.com/users/1?verbose=true"},
+        "response": {
+          "status": 200,
+          "headers": [{"name": "X-Test", "value": "verbose"}, {"name": "Content-Length", "value": "99"}],
+          "content": {"mimeType": "application/json", "text": "{\"name\": \"verbose-user\"}"}
+        }
+      },
+      {
+        "request": {
+          "method": "POST",
+          "url": "https://example.com/users",
+          "postData": {"mimeType": "application/json", "text": "{\"name\": \"new-user\"}"}
+        },
+        "response": {
+          "status": 201,
+          "content": {"mimeType": "application/json", "text": "eyJpZCI6IDJ9", "encoding": "base64"}
+        }
+      },
+      {
+        "request": {"method": "GET", "url": "https://example.com/index.html"},
+        "response": {"status": 200, "content": {"mimeType": "text/html", "text": "<html></html>"}}
+      }
+    ]
+  }
+}`)
+
+	h, skipped, err := LoadHAR(filepath.Join(dir, "capture.har"))
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if len(skipped) != 1 {
+		t.Fatalf("want 1 skipped entry, but got %v", skipped)
+	}
+
+	cases := []struct {
+		method   string
+		target   string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{method: "GET", target: "/users/1?verbose=true", wantCode: 200, wantBody: `{"name":"verbose-user"}`},
+		{method: "GET", target: "/users/1", wantCode: 404},
+		{method: "POST", target: "/users", body: `{"name":"new-user"}`, wantCode: 201, wantBody: `{"id":2}`},
+		{method: "POST", target: "/users", body: `{"name":"other-user"}`, wantCode: 404},
+		{method: "GET", target: "/index.html", wantCode: 404},
+	}
+	for _, tt := range cases {
+		t.Run(tt.method+tt.target, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tt.method, "http://localhost"+tt.target, strings.NewReader(tt.body)))
+			if w.Code != tt.wantCode {
+				t.Fatalf("want %v, but got %v", tt.wantCode, w.Code)
+			}
+			if tt.wantBody == "" {
+				return
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.wantBody {
+				t.Fatalf("want %v, but got %v", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestJournal_WriteHAR(t *testing.T) {
+	h := NewMultipleHandler([]JSONHandler{
+		{
+			Method:       "POST",
+			PathFmt:      "/users",
+			ResponseCode: 201,
+			ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+				return map[string]int{"id": 2}, nil
+			},
+		},
+	})
+	h.Journal = &Journal{}
+
+	req := httptest.NewRequest("POST", "http://example.com/users?dry=1", strings.NewReader(`{"name":"new-user"}`))
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var b bytes.Buffer
+	if err := h.Journal.WriteHAR(&b); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "fakehttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile(t, dir, "journal.har", b.String())
+
+	replay, skipped, err := LoadHAR(filepath.Join(dir, "journal.har"))
+	if err != nil || len(skipped) != 0 {
+		t.Fatalf("should not be error, but: %v %v", err, skipped)
+	}
+	w := httptest.NewRecorder()
+	replay.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost/users?dry=1", strings.NewReader(`{"name":"new-user"}`)))
+	if w.Code != 201 || strings.TrimSpace(w.Body.String()) != `{"id":2}` {
+		t.Fatalf("unexpected replayed response: %v %v", w.Code, w.Body.String())
+	}
+}
+
+func TestJournal_WriteHAR_binary(t *testing.T) {
+	h := NewMultipleHandler([]JSONHandler{
+		{
+			Method:       "POST",
+			PathFmt:      "/upload",
+			ResponseCode: 200,
+			Compression:  &Compression{Force: "gzip"},
+			ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+				return map[string]string{"status": "ok"}, nil
+			},
+		},
+	})
+	h.Journal = &Journal{}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "http://localhost/upload", strings.NewReader("\xff\x00binary")))
+
+	var b bytes.Buffer
+	if err := h.Journal.WriteHAR(&b); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	f := harFile{}
+	if err := json.Unmarshal(b.Bytes(), &f); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	e := f.Log.Entries[0]
+
+	content := e.Response.Content
+	if content.Encoding != "base64" {
+		t.Fatalf("want base64 encoding, but got %q", content.Encoding)
+	}
+	body, _ := base64.StdEncoding.DecodeString(content.Text)
+	got, err := decompress(body, "gzip")
+	if err != nil || string(got) != `{"status":"ok"}`+"\n" {
+		t.Fatalf("unexpected response body: %q %v", got, err)
+	}
+
+	postData := e.Request.PostData
+	if postData.Encoding != "base64" || postData.Text != base64.StdEncoding.EncodeToString([]byte("\xff\x00binary")) {
+		t.Fatalf("unexpected postData: %+v", postData)
+	}
+}
@@ -0,0 +1,209 @@
+package fakehttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"sync"
+)
+
+// IdempotencyEntry is a response cached by IdempotencyCache.
+type IdempotencyEntry struct {
+	// Key is the value of the idempotency key header.
+	Key string
+	// Fingerprint identifies the request by its method, URL and body.
+	Fingerprint string
+	// StatusCode is the HTTP response code.
+	StatusCode int
+	// Header is the HTTP response header.
+	Header http.Header
+	// Body is the HTTP response body.
+	Body string
+	// Replays is the number of times the response was replayed.
+	Replays int
+
+	// uncompressed is the body before compression, or nil if the body is not
+	// compressed.
+	uncompressed []byte
+}
+
+// IdempotencyCache caches the first response per idempotency key, and replays
+// it when the request is retried with the same key, so that the retries do
+// not have side effects twice.  The response header of a replay has
+// `Idempotent-Replayed: true`.
+//
+// A request reusing a key with a different method, URL or body is responded
+// with 422 Unprocessable Entity, and a request with a key whose first request
+// is still in progress with 409 Conflict.  The requests without the key are
+// served normally.  5xx and 429 responses are not cached so that they can be
+// retried.  A compressed response is compressed again for each replay
+// according to its Accept-Encoding.
+//
+// An IdempotencyCache can be shared by several JSONHandlers, and it is safe
+// for concurrent use.
+type IdempotencyCache struct {
+	// Header is the name of the HTTP request header holding the key.
+	// Defaults to "Idempotency-Key".
+	Header string
+
+	mu         sync.Mutex
+	entries    map[string]*IdempotencyEntry
+	inProgress map[string]bool
+}
+
+// NewIdempotencyCache creates an instance of IdempotencyCache.
+func NewIdempotencyCache() *IdempotencyCache {
+	return &IdempotencyCache{Header: "Idempotency-Key"}
+}
+
+// Get returns the response cached for the key, and reports whether it exists.
+func (c *IdempotencyCache) Get(key string) (IdempotencyEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return IdempotencyEntry{}, false
+	}
+	return *e, true
+}
+
+// Entries returns all the cached responses.
+func (c *IdempotencyCache) Entries() []IdempotencyEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ret := make([]IdempotencyEntry, 0, len(c.entries))
+	for _, e := range c.entries {
+		ret = append(ret, *e)
+	}
+	return ret
+}
+
+// Reset removes all the cached responses.
+func (c *IdempotencyCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = nil
+}
+
+// serve replays the cached response to the request, or serves it with serve
+// and caches the response.  fail writes an error response.  A body longer than
+// maxBodySize, if it is positive, is served without caching since it is
+// rejected by serve.  compression compresses the replays as serve does.
+func (c *IdempotencyCache) serve(w http.ResponseWriter, r *http.Request, maxBodySize int64, compression *Compression, serve func(http.ResponseWriter), fail func(http.ResponseWriter, error, int)) {
+	name := c.Header
+	if name == "" {
+		name = "Idempotency-Key"
+	}
+	key := r.Header.Get(name)
+	if key == "" {
+		serve(w)
+		return
+	}
+	body, truncated, err := peekBodyLimit(r, maxBodySize)
+	if err != nil {
+		fail(w, err, http.StatusBadRequest)
+		return
+	}
+	if truncated {
+		serve(w)
+		return
+	}
+	sum := sha256.Sum256([]byte(r.Method + " " + r.URL.RequestURI() + "\n" + string(body)))
+	fingerprint := hex.EncodeToString(sum[:])
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		if e.Fingerprint != fingerprint {
+			c.mu.Unlock()
+			fail(w, errors.New("idempotency key reused with a different request"), http.StatusUnprocessableEntity)
+			return
+		}
+		e.Replays++
+		replay := *e
+		c.mu.Unlock()
+		for k, vs := range replay.Header {
+			w.Header()[k] = vs
+		}
+		body := []byte(replay.Body)
+		if replay.uncompressed != nil {
+			body = replay.uncompressed
+			w.Header().Del("Content-Encoding")
+			enc := compression.encoding(r)
+			if enc != "" {
+				compressed, err := compression.compress(body, enc)
+				if err != nil {
+					fail(w, err, http.StatusInternalServerError)
+					return
+				}
+				body = compressed
+				w.Header().Set("Content-Encoding", enc)
+			}
+			if etag := w.Header().Get("ETag"); etag != "" {
+				w.Header().Set("ETag", encodedETag(decodedETag(etag), enc))
+			}
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(replay.StatusCode)
+		w.Write(body)
+		return
+	}
+	if c.inProgress[key] {
+		c.mu.Unlock()
+		fail(w, errors.New("request with the idempotency key is in progress"), http.StatusConflict)
+		return
+	}
+	if c.inProgress == nil {
+		c.inProgress = map[string]bool{}
+	}
+	c.inProgress[key] = true
+	c.mu.Unlock()
+
+	sw := &idempotencyWriter{statusWriter: statusWriter{ResponseWriter: w}}
+	defer func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		delete(c.inProgress, key)
+	}()
+	serve(sw)
+
+	statusCode := sw.statusCode
+	if statusCode == 0 {
+		statusCode = http.StatusOK
+	}
+	if sw.hijacked || statusCode == http.StatusTooManyRequests || statusCode >= 500 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	header := w.Header().Clone()
+	header.Del("Date")
+	if c.entries == nil {
+		c.entries = map[string]*IdempotencyEntry{}
+	}
+	c.entries[key] = &IdempotencyEntry{
+		Key:          key,
+		Fingerprint:  fingerprint,
+		StatusCode:   statusCode,
+		Header:       header,
+		Body:         sw.body.String(),
+		uncompressed: sw.uncompressed,
+	}
+}
+
+// idempotencyWriter is a statusWriter which also records the response body
+// before compression.
+type idempotencyWriter struct {
+	statusWriter
+	uncompressed []byte
+}
+
+func (w *idempotencyWriter) recordUncompressed(b []byte) {
+	w.uncompressed = b
+}
+
+// uncompressedRecorder is implemented by the http.ResponseWriter that records
+// the response body before compression.
+type uncompressedRecorder interface {
+	recordUncompressed([]byte)
+}
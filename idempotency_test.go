@@ -0,0 +1,145 @@
+package fakehttp
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestJSONHandler_ServeHTTP_idempotency(t *testing.T) {
+	res := NewResource("/payments", map[string]interface{}{})
+	cache := NewIdempotencyCache()
+	handlers := res.Handlers()
+	for i := range handlers {
+		handlers[i].Idempotency = cache
+	}
+	h := NewMultipleHandler(handlers)
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "http://localhost/payments", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			r.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	first := post("a", `{"amount":100}`)
+	if first.Code != 201 {
+		t.Fatalf("want 201, but got %v", first.Code)
+	}
+	retry := post("a", `{"amount":100}`)
+	if retry.Code != 201 || retry.Body.String() != first.Body.String() {
+		t.Fatalf("want the replay of %v, but got %v %v", first.Body.String(), retry.Code, retry.Body.String())
+	}
+	if got := retry.Header().Get("Idempotent-Replayed"); got != "true" {
+		t.Fatalf("want Idempotent-Replayed header, but got %v", retry.Header())
+	}
+	if got := len(res.List()); got != 1 {
+		t.Fatalf("want 1 payment, but got %v", got)
+	}
+
+	if w := post("a", `{"amount":200}`); w.Code != 422 {
+		t.Fatalf("want 422 for a different body, but got %v", w.Code)
+	}
+	if w := post("", `{"amount":100}`); w.Code != 201 {
+		t.Fatalf("want 201 without the key, but got %v", w.Code)
+	}
+	if got := len(res.List()); got != 2 {
+		t.Fatalf("want 2 payments, but got %v", got)
+	}
+
+	e, ok := cache.Get("a")
+	if !ok {
+		t.Fatalf("want the cached response, but not found")
+	}
+	if e.StatusCode != 201 || e.Replays != 1 {
+		t.Fatalf("unexpected entry: %+v", e)
+	}
+	if got := len(cache.Entries()); got != 1 {
+		t.Fatalf("want 1 entry, but got %v", got)
+	}
+
+	cache.Reset()
+	if _, ok := cache.Get("a"); ok {
+		t.Fatalf("want no entry after reset, but found")
+	}
+}
+
+func TestJSONHandler_ServeHTTP_idempotencyInProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := JSONHandler{
+		Method:       "POST",
+		PathFmt:      "/payments",
+		ResponseCode: 201,
+		Idempotency:  NewIdempotencyCache(),
+		ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+			close(started)
+			<-release
+			return map[string]int{"id": 1}, nil
+		},
+	}
+	request := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "http://localhost/payments", nil)
+		r.Header.Set("Idempotency-Key", "a")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		done <- request()
+	}()
+	<-started
+	if w := request(); w.Code != 409 {
+		t.Fatalf("want 409 while in progress, but got %v", w.Code)
+	}
+	close(release)
+	if w := <-done; w.Code != 201 {
+		t.Fatalf("want 201, but got %v", w.Code)
+	}
+}
+
+func TestJSONHandler_ServeHTTP_idempotencyCompression(t *testing.T) {
+	h := JSONHandler{
+		Method:       "POST",
+		PathFmt:      "/payments",
+		ResponseCode: 201,
+		Idempotency:  NewIdempotencyCache(),
+		Compression:  &Compression{},
+		ResponseFn: func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+			return map[string]int{"id": 1}, nil
+		},
+	}
+	post := func(acceptEncoding string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "http://localhost/payments", nil)
+		r.Header.Set("Idempotency-Key", "a")
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	want := `{"id":1}` + "\n"
+	for _, enc := range []string{"gzip", "", "deflate", "gzip"} {
+		w := post(enc)
+		if got := w.Header().Get("Content-Encoding"); got != enc {
+			t.Fatalf("want Content-Encoding %q, but got %q", enc, got)
+		}
+		body := w.Body.Bytes()
+		if enc != "" {
+			var err error
+			if body, err = decompress(body, enc); err != nil {
+				t.Fatalf("should not be error, but: %v", err)
+			}
+		}
+		if string(body) != want {
+			t.Fatalf("want %q, but got %q", want, body)
+		}
+	}
+}
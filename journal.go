@@ -0,0 +1,173 @@
+package fakehttp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// JournalEntry is a record of an HTTP request served by MultipleHandler.
+type JournalEntry struct {
+	// Time is the time when the request was received.
+	Time time.Time
+	// Method is the HTTP request method.
+	Method string
+	// Host is the host of the request.
+	Host string
+	// URL is the request URI.
+	URL string
+	// Header is the HTTP request header.
+	Header http.Header
+	// Body is the HTTP request body.  It is truncated to
+	// JSONHandler.MaxRequestBodySize of the matched JSONHandler, or of the
+	// first JSONHandler bound to the HTTP method and the URL path if none
+	// matches.
+	Body string
+	// BodyTruncated reports whether Body was truncated.
+	BodyTruncated bool
+	// Parts are the parts of the HTTP request body if it is
+	// multipart/form-data and not too large.
+	Parts []MultipartPart
+	// Matched reports whether the request matched any JSONHandler.
+	Matched bool
+	// StatusCode is the HTTP response code, or 0 if the connection was closed
+	// without responding.
+	StatusCode int
+	// ResponseHeader is the HTTP response header.
+	ResponseHeader http.Header
+	// ResponseBody is the HTTP response body.  It is truncated to
+	// Journal.MaxResponseBodySize, e.g. for a long stream of events.
+	ResponseBody string
+	// ResponseBodyTruncated reports whether ResponseBody was truncated.
+	ResponseBodyTruncated bool
+	// Duration is the time taken to serve the request.
+	Duration time.Duration
+	// WebSocket is the conversation in the order sent if the request was
+	// upgraded to WebSocket by WebSocketHandler.
+	WebSocket []WebSocketMessage
+	// GraphQL is the GraphQL operation if the request was served by
+	// GraphQLHandler.
+	GraphQL *GraphQLRequest
+	// Chaos is the failure injected by Chaos, or an empty string if the
+	// response was not affected by Chaos.
+	Chaos ChaosEvent
+}
+
+// Journal records the HTTP requests served by MultipleHandler.
+// The zero value is an empty journal ready to use, and it is safe for
+// concurrent use.
+type Journal struct {
+	// MaxResponseBodySize is the maximum size of the response body recorded
+	// per entry.  Defaults to DefaultMaxResponseBodySize.
+	MaxResponseBodySize int
+
+	mu      sync.Mutex
+	entries []JournalEntry
+}
+
+// DefaultMaxResponseBodySize is the default of Journal.MaxResponseBodySize.
+const DefaultMaxResponseBodySize = 64 << 10
+
+func (j *Journal) maxResponseBodySize() int {
+	if j.MaxResponseBodySize > 0 {
+		return j.MaxResponseBodySize
+	}
+	return DefaultMaxResponseBodySize
+}
+
+// Entries returns all the recorded entries in the order received.
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]JournalEntry{}, j.entries...)
+}
+
+// Unmatched returns the recorded entries that did not match any JSONHandler.
+func (j *Journal) Unmatched() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	ret := []JournalEntry{}
+	for _, e := range j.entries {
+		if !e.Matched {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+// Reset removes all the recorded entries.
+func (j *Journal) Reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = nil
+}
+
+func (j *Journal) record(e JournalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = append(j.entries, e)
+}
+
+// statusWriter is an http.ResponseWriter that records the HTTP response code
+// and body.  It implements http.Flusher and http.Hijacker by delegating to the
+// underlying http.ResponseWriter; Flush does nothing and Hijack fails if it
+// does not support them.
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode int
+	body       bytes.Buffer
+	// maxBody is the maximum size of body if it is positive.
+	maxBody   int
+	truncated bool
+	hijacked  bool
+	webSocket []WebSocketMessage
+	graphQL   *GraphQLRequest
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	rec := b
+	if w.maxBody > 0 && w.body.Len()+len(rec) > w.maxBody {
+		rec = rec[:w.maxBody-w.body.Len()]
+		w.truncated = true
+	}
+	w.body.Write(rec)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) recordWebSocket(messages []WebSocketMessage) {
+	w.statusCode = http.StatusSwitchingProtocols
+	w.webSocket = messages
+}
+
+func (w *statusWriter) recordGraphQL(req GraphQLRequest) {
+	w.graphQL = &req
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack is not supported")
+	}
+	w.hijacked = true
+	return h.Hijack()
+}
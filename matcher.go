@@ -0,0 +1,153 @@
+package fakehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"regexp"
+)
+
+// Matcher reports whether the HTTP request satisfies a condition.
+// A Matcher that reads the request body must restore it so that subsequent
+// matchers and the handler can read it again.  The Matchers returned by the
+// functions in this package do not match a body larger than
+// JSONHandler.MaxRequestBodySize.
+type Matcher func(r *http.Request) bool
+
+// HeaderMatcher returns a Matcher that checks the HTTP request header with
+// the key has the value.
+func HeaderMatcher(key, value string) Matcher {
+	return func(r *http.Request) bool {
+		for _, v := range r.Header.Values(key) {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// QueryMatcher returns a Matcher that checks the URL query parameter with the
+// key has the value.
+func QueryMatcher(key, value string) Matcher {
+	return func(r *http.Request) bool {
+		for _, v := range r.URL.Query()[key] {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// BodyJSONMatcher returns a Matcher that checks the HTTP request body is JSON
+// equivalent to the value.  Object key order and whitespace are ignored.
+func BodyJSONMatcher(value interface{}) Matcher {
+	want, err := normalizeJSON(value)
+	if err != nil {
+		return func(*http.Request) bool { return false }
+	}
+
+	return func(r *http.Request) bool {
+		body, err := peekMatchedBody(r)
+		if err != nil {
+			return false
+		}
+		return equalJSON(want, body)
+	}
+}
+
+// normalizeJSON converts the value to the form decoded from its JSON by
+// json.Unmarshal(), so that it can be compared with equalJSON().
+func normalizeJSON(value interface{}) (interface{}, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var ret interface{}
+	err = json.Unmarshal(b, &ret)
+	return ret, err
+}
+
+// equalJSON reports whether b is JSON equivalent to want, which is returned
+// by normalizeJSON().
+func equalJSON(want interface{}, b []byte) bool {
+	var got interface{}
+	if err := json.Unmarshal(b, &got); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(want, got)
+}
+
+// peekBody reads the HTTP request body and replaces it with an unread copy.
+func peekBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return []byte{}, nil
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, err
+}
+
+// peekBodyLimit is like peekBody, but reads at most n bytes if n is positive.
+// It reports whether the body is longer than n, in which case the rest of the
+// body is left unread in r.Body after the returned bytes.
+func peekBodyLimit(r *http.Request, n int64) ([]byte, bool, error) {
+	if n <= 0 || r.Body == nil {
+		b, err := peekBody(r)
+		return b, false, err
+	}
+	b, err := ioutil.ReadAll(io.LimitReader(r.Body, n+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(b), r.Body), r.Body}
+	if int64(len(b)) > n {
+		return b[:n], true, err
+	}
+	return b, false, err
+}
+
+// bodyLimitKey is the context key of the maximum size of the HTTP request body
+// read by the body matchers.
+type bodyLimitKey struct{}
+
+// peekMatchedBody is like peekBody, but returns errBodyTooLarge if the body is
+// larger than JSONHandler.MaxRequestBodySize of the handler running the
+// matcher.
+func peekMatchedBody(r *http.Request) ([]byte, error) {
+	n, _ := r.Context().Value(bodyLimitKey{}).(int64)
+	b, truncated, err := peekBodyLimit(r, n)
+	if err == nil && truncated {
+		err = errBodyTooLarge
+	}
+	return b, err
+}
+
+// BodyContainsMatcher returns a Matcher that checks the HTTP request body
+// contains the substring.
+func BodyContainsMatcher(substr string) Matcher {
+	return func(r *http.Request) bool {
+		body, err := peekMatchedBody(r)
+		if err != nil {
+			return false
+		}
+		return bytes.Contains(body, []byte(substr))
+	}
+}
+
+// BodyRegexpMatcher returns a Matcher that checks the HTTP request body
+// matches the regular expression.
+func BodyRegexpMatcher(re *regexp.Regexp) Matcher {
+	return func(r *http.Request) bool {
+		body, err := peekMatchedBody(r)
+		if err != nil {
+			return false
+		}
+		return re.Match(body)
+	}
+}
@@ -0,0 +1,126 @@
+package fakehttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/url"
+)
+
+// MultipartPart is a part of a multipart/form-data request body.
+type MultipartPart struct {
+	// Name is the form field name.
+	Name string
+	// FileName is the name of the uploaded file, or an empty string if the
+	// part is not a file.
+	FileName string
+	// ContentType is the Content-Type of the part.
+	ContentType string
+	// Data is the content of the part.
+	Data []byte
+}
+
+// MultipartForm is a multipart/form-data request body.  Specify
+// &MultipartForm{} as JSONHandler.RequestBody, or return it from
+// JSONHandler.NewRequestBody, to receive the parsed parts in ResponseFn.
+type MultipartForm struct {
+	// Parts are the parts in the order sent.
+	Parts []MultipartPart
+}
+
+// Value returns the first value of the form field, or an empty string if
+// there is no such field.
+func (f *MultipartForm) Value(name string) string {
+	for _, p := range f.Parts {
+		if p.Name == name && p.FileName == "" {
+			return string(p.Data)
+		}
+	}
+	return ""
+}
+
+// Values returns the form fields other than the files.
+func (f *MultipartForm) Values() url.Values {
+	values := url.Values{}
+	for _, p := range f.Parts {
+		if p.FileName == "" {
+			values.Add(p.Name, string(p.Data))
+		}
+	}
+	return values
+}
+
+// File returns the first file uploaded as the form field, and reports
+// whether it exists.
+func (f *MultipartForm) File(name string) (MultipartPart, bool) {
+	for _, p := range f.Parts {
+		if p.Name == name && p.FileName != "" {
+			return p, true
+		}
+	}
+	return MultipartPart{}, false
+}
+
+// parseMultipart reads the parts of a multipart/form-data body.
+func parseMultipart(contentType string, body io.Reader) ([]MultipartPart, error) {
+	mt, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mt != "multipart/form-data" {
+		return nil, fmt.Errorf("invalid Content-Type: want multipart/form-data, got %v", contentType)
+	}
+	r := multipart.NewReader(body, params["boundary"])
+	parts := []MultipartPart{}
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(p)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, MultipartPart{
+			Name:        p.FormName(),
+			FileName:    p.FileName(),
+			ContentType: p.Header.Get("Content-Type"),
+			Data:        data,
+		})
+	}
+}
+
+// errBodyTooLarge is returned by the reader returned by limitBody.
+var errBodyTooLarge = errors.New("request body too large")
+
+// limitBody returns a reader that returns errBodyTooLarge if r has more than n
+// bytes.  It returns r if n is not positive.
+func limitBody(r io.Reader, n int64) io.Reader {
+	if n <= 0 {
+		return r
+	}
+	return &limitedReader{r: r, n: n}
+}
+
+type limitedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, errBodyTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,234 @@
+package fakehttp
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newMultipartBody(t *testing.T) (string, []byte) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	if err := w.WriteField("title", "report"); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="file"; filename="a.csv"`)
+	header.Set("Content-Type", "text/csv")
+	part, err := w.CreatePart(header)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	part.Write([]byte("a,b\n1,2\n"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	return w.FormDataContentType(), b.Bytes()
+}
+
+func TestJSONHandler_ServeHTTP_multipart(t *testing.T) {
+	contentType, body := newMultipartBody(t)
+
+	var got *MultipartForm
+	h := JSONHandler{
+		Method:         "POST",
+		PathFmt:        "/files",
+		ResponseCode:   201,
+		NewRequestBody: func() interface{} { return &MultipartForm{} },
+		ResponseFn: func(body interface{}, _ []string, _ url.Values) (interface{}, error) {
+			got = body.(*MultipartForm)
+			f, _ := got.File("file")
+			return map[string]interface{}{"title": got.Value("title"), "size": len(f.Data)}, nil
+		},
+	}
+	m := NewMultipleHandler([]JSONHandler{h})
+	m.Journal = &Journal{}
+
+	r := httptest.NewRequest("POST", "http://localhost/files", bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	m.ServeHTTP(w, r)
+
+	if w.Code != 201 {
+		t.Fatalf("want 201, but got %v: %v", w.Code, w.Body.String())
+	}
+	if want := `{"size":8,"title":"report"}` + "\n"; w.Body.String() != want {
+		t.Fatalf("want %v, but got %v", want, w.Body.String())
+	}
+	if want := (url.Values{"title": {"report"}}); got.Values().Encode() != want.Encode() {
+		t.Fatalf("want %v, but got %v", want, got.Values())
+	}
+	f, ok := got.File("file")
+	if !ok || f.FileName != "a.csv" || f.ContentType != "text/csv" || string(f.Data) != "a,b\n1,2\n" {
+		t.Fatalf("unexpected file: %+v", f)
+	}
+	if _, ok := got.File("title"); ok {
+		t.Fatalf("want no file for a field, but found")
+	}
+
+	entries := m.Journal.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("want 1 entry, but got %v", len(entries))
+	}
+	parts := entries[0].Parts
+	if len(parts) != 2 || parts[0].Name != "title" || parts[1].FileName != "a.csv" {
+		t.Fatalf("unexpected parts: %+v", parts)
+	}
+}
+
+func TestJSONHandler_ServeHTTP_multipartErrors(t *testing.T) {
+	contentType, body := newMultipartBody(t)
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        []byte
+		maxSize     int64
+		want        int
+	}{
+		{name: "within_limit", contentType: contentType, body: body, maxSize: int64(len(body)), want: 200},
+		{name: "too_large", contentType: contentType, body: body, maxSize: int64(len(body)) / 2, want: 413},
+		{name: "not_multipart", contentType: "application/json", body: []byte(`{}`), want: 400},
+		{name: "malformed", contentType: contentType, body: []byte("broken"), want: 400},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			h := JSONHandler{
+				Method:             "POST",
+				PathFmt:            "/files",
+				ResponseCode:       200,
+				RequestBody:        &MultipartForm{},
+				MaxRequestBodySize: tt.maxSize,
+			}
+			r := httptest.NewRequest("POST", "http://localhost/files", bytes.NewReader(tt.body))
+			r.Header.Set("Content-Type", tt.contentType)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("want %v, but got %v: %v", tt.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestJSONHandler_ServeHTTP_maxRequestBodySize(t *testing.T) {
+	h := JSONHandler{
+		Method:             "POST",
+		PathFmt:            "/items",
+		ResponseCode:       200,
+		RequestBody:        &testItem{},
+		MaxRequestBodySize: 8,
+	}
+	r := httptest.NewRequest("POST", "http://localhost/items", strings.NewReader(`{"name":"long name"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != 413 {
+		t.Fatalf("want 413, but got %v", w.Code)
+	}
+}
+
+func TestMultipleHandler_ServeHTTP_maxRequestBodySize(t *testing.T) {
+	cache := NewIdempotencyCache()
+	h := NewMultipleHandler([]JSONHandler{{
+		Method:             "POST",
+		PathFmt:            "/files",
+		ResponseCode:       201,
+		RequestBody:        &MultipartForm{},
+		MaxRequestBodySize: 1024,
+		Idempotency:        cache,
+	}})
+	h.Journal = &Journal{}
+
+	var b bytes.Buffer
+	mw := multipart.NewWriter(&b)
+	part, err := mw.CreateFormFile("file", "large.bin")
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	part.Write(bytes.Repeat([]byte("a"), 1<<20))
+	mw.Close()
+	r := httptest.NewRequest("POST", "http://localhost/files", &b)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	r.Header.Set("Idempotency-Key", "k1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	if w.Code != 413 {
+		t.Fatalf("want 413, but got %v", w.Code)
+	}
+	entries := h.Journal.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("want 1 entry, but got %v", len(entries))
+	}
+	if e := entries[0]; len(e.Body) != 1024 || !e.BodyTruncated || e.Parts != nil {
+		t.Fatalf("want the body truncated to 1024 bytes without parts, but got %v bytes, %v, %v", len(e.Body), e.BodyTruncated, e.Parts)
+	}
+	if got := cache.Entries(); len(got) != 0 {
+		t.Fatalf("want no cached response, but got %v", got)
+	}
+}
+
+// countingReader counts the bytes read.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
+func TestMultipleHandler_ServeHTTP_maxRequestBodySizeMatchers(t *testing.T) {
+	calls := 0
+	h := NewMultipleHandler([]JSONHandler{{
+		Method:       "POST",
+		PathFmt:      "/items",
+		ResponseCode: 201,
+		Matchers: []Matcher{
+			func(*http.Request) bool {
+				calls++
+				return true
+			},
+			BodyContainsMatcher("name"),
+		},
+		MaxRequestBodySize: 1024,
+	}})
+	h.Journal = &Journal{}
+
+	body := &countingReader{r: strings.NewReader(`{"name":"` + strings.Repeat("a", 1<<20) + `"}`)}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost/items", body))
+
+	if w.Code != 404 {
+		t.Fatalf("want 404, but got %v", w.Code)
+	}
+	if calls != 1 {
+		t.Fatalf("want the matchers called once, but got %v", calls)
+	}
+	if body.n > 1<<16 {
+		t.Fatalf("want the body read up to the limit, but read %v bytes", body.n)
+	}
+	entries := h.Journal.Entries()
+	if len(entries) != 1 || len(entries[0].Body) != 1024 || !entries[0].BodyTruncated {
+		t.Fatalf("want the body truncated to 1024 bytes, but got %+v", entries)
+	}
+
+	calls = 0
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost/items", strings.NewReader(`{"name":"a"}`)))
+	if w.Code != 201 || calls != 1 {
+		t.Fatalf("want 201 with the matchers called once, but got %v and %v calls", w.Code, calls)
+	}
+}
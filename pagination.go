@@ -0,0 +1,206 @@
+package fakehttp
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// PaginationStyle is a way to paginate a list endpoint.
+type PaginationStyle int
+
+const (
+	// CursorPagination reads the `cursor` and `limit` query parameters, and
+	// responds with `{"items": [...], "nextCursor": "..."}`.  nextCursor is
+	// an opaque token, or null on the last page.
+	CursorPagination PaginationStyle = iota
+	// OffsetPagination reads the `offset` and `limit` query parameters, and
+	// responds with `{"items": [...], "offset": 0, "limit": 10, "total": 42}`.
+	OffsetPagination
+	// PagePagination reads the `page` and `per_page` query parameters, where
+	// the first page is 1, and responds with
+	// `{"items": [...], "page": 1, "perPage": 10, "total": 42, "totalPages": 5}`.
+	PagePagination
+	// LinkPagination reads the `page` and `per_page` query parameters like
+	// PagePagination, and responds with the array of the items and a
+	// `Link: <...>; rel="next"` header unless it is the last page.
+	LinkPagination
+)
+
+// Paginator slices a collection into pages according to the query parameters
+// of the request.
+type Paginator struct {
+	// Style is the way to paginate.
+	Style PaginationStyle
+	// PageSize is the number of items in a page if the request does not
+	// specify it.  Defaults to 10.
+	PageSize int
+	// MaxPageSize limits the number of items in a page if it is positive.
+	MaxPageSize int
+	// PositionParam overrides the name of the query parameter specifying the
+	// page, such as `cursor`, `offset` or `page`.
+	PositionParam string
+	// SizeParam overrides the name of the query parameter specifying the
+	// number of items in a page, such as `limit` or `per_page`.
+	SizeParam string
+	// URL is the URL of the list endpoint used in the Link header, such as
+	// `http://localhost/users`.  If it is an empty string, the Link header has
+	// a URL reference relative to the request URL.
+	URL string
+}
+
+// ResponseFn returns a function to be used as JSONHandler.ResponseFn, which
+// responds with a page of the items returned by list.
+func (p Paginator) ResponseFn(list func() []interface{}) func(interface{}, []string, url.Values) (interface{}, error) {
+	return func(_ interface{}, _ []string, query url.Values) (interface{}, error) {
+		return p.Paginate(list(), query)
+	}
+}
+
+// Paginate returns the response of the page of items specified by the query
+// parameters.
+func (p Paginator) Paginate(items []interface{}, query url.Values) (Response, error) {
+	size, err := p.size(query)
+	if err != nil {
+		return Response{}, err
+	}
+	offset, err := p.offset(query, size)
+	if err != nil {
+		return Response{}, err
+	}
+	total := len(items)
+	// The page number is of the requested position even if it is past the
+	// end.
+	pageNumber := offset/size + 1
+	if offset > total {
+		offset = total
+	}
+	end := offset + size
+	if end > total {
+		end = total
+	}
+	page := append([]interface{}{}, items[offset:end]...)
+	more := end < total
+
+	switch p.Style {
+	case CursorPagination:
+		var next interface{}
+		if more {
+			next = encodeCursor(end)
+		}
+		return Response{Body: map[string]interface{}{"items": page, "nextCursor": next}}, nil
+	case OffsetPagination:
+		return Response{Body: map[string]interface{}{"items": page, "offset": offset, "limit": size, "total": total}}, nil
+	case PagePagination:
+		return Response{Body: map[string]interface{}{
+			"items":      page,
+			"page":       pageNumber,
+			"perPage":    size,
+			"total":      total,
+			"totalPages": (total + size - 1) / size,
+		}}, nil
+	case LinkPagination:
+		res := Response{Body: page}
+		if more {
+			q := url.Values{}
+			for k, v := range query {
+				q[k] = v
+			}
+			q.Set(p.positionParam(), strconv.Itoa(offset/size+2))
+			q.Set(p.sizeParam(), strconv.Itoa(size))
+			res.Header = http.Header{"Link": {fmt.Sprintf(`<%v?%v>; rel="next"`, p.URL, q.Encode())}}
+		}
+		return res, nil
+	}
+	return Response{}, fmt.Errorf("unknown pagination style: %v", p.Style)
+}
+
+func (p Paginator) positionParam() string {
+	if p.PositionParam != "" {
+		return p.PositionParam
+	}
+	switch p.Style {
+	case CursorPagination:
+		return "cursor"
+	case OffsetPagination:
+		return "offset"
+	}
+	return "page"
+}
+
+func (p Paginator) sizeParam() string {
+	if p.SizeParam != "" {
+		return p.SizeParam
+	}
+	if p.Style == PagePagination || p.Style == LinkPagination {
+		return "per_page"
+	}
+	return "limit"
+}
+
+func (p Paginator) size(query url.Values) (int, error) {
+	size := p.PageSize
+	if size <= 0 {
+		size = 10
+	}
+	if v := query.Get(p.sizeParam()); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid %v: %v", p.sizeParam(), v)
+		}
+		size = n
+	}
+	if p.MaxPageSize > 0 && size > p.MaxPageSize {
+		size = p.MaxPageSize
+	}
+	return size, nil
+}
+
+// offset returns the index of the first item in the page.
+func (p Paginator) offset(query url.Values, size int) (int, error) {
+	v := query.Get(p.positionParam())
+	if v == "" {
+		return 0, nil
+	}
+	switch p.Style {
+	case CursorPagination:
+		offset, err := decodeCursor(v)
+		if err != nil {
+			return 0, fmt.Errorf("invalid %v: %v", p.positionParam(), v)
+		}
+		return offset, nil
+	case OffsetPagination:
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid %v: %v", p.positionParam(), v)
+		}
+		return n, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid %v: %v", p.positionParam(), v)
+	}
+	return (n - 1) * size, nil
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	var offset int
+	if _, err := fmt.Sscanf(string(b), "offset:%d", &offset); err != nil {
+		return 0, err
+	}
+	if offset < 0 {
+		return 0, errors.New("negative offset")
+	}
+	return offset, nil
+}
@@ -0,0 +1,106 @@
+package fakehttp
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPaginator(t *testing.T) {
+	items := []interface{}{1, 2, 3, 4, 5}
+
+	cases := []struct {
+		name     string
+		style    PaginationStyle
+		query    string
+		wantBody string
+		wantLink string
+		wantCode int
+	}{
+		{name: "cursor_first", style: CursorPagination, query: "limit=2", wantBody: `{"items":[1,2],"nextCursor":"b2Zmc2V0OjI"}`},
+		{name: "cursor_next", style: CursorPagination, query: "limit=2&cursor=b2Zmc2V0OjI", wantBody: `{"items":[3,4],"nextCursor":"b2Zmc2V0OjQ"}`},
+		{name: "cursor_last", style: CursorPagination, query: "limit=2&cursor=b2Zmc2V0OjQ", wantBody: `{"items":[5],"nextCursor":null}`},
+		{name: "cursor_invalid", style: CursorPagination, query: "cursor=x", wantCode: 400},
+		{name: "offset", style: OffsetPagination, query: "offset=3&limit=10", wantBody: `{"items":[4,5],"limit":10,"offset":3,"total":5}`},
+		{name: "offset_beyond", style: OffsetPagination, query: "offset=10", wantBody: `{"items":[],"limit":10,"offset":5,"total":5}`},
+		{name: "page", style: PagePagination, query: "page=2&per_page=2", wantBody: `{"items":[3,4],"page":2,"perPage":2,"total":5,"totalPages":3}`},
+		{name: "page_past_end", style: PagePagination, query: "page=10&per_page=10", wantBody: `{"items":[],"page":10,"perPage":10,"total":5,"totalPages":1}`},
+		{name: "page_invalid", style: PagePagination, query: "page=0", wantCode: 400},
+		{name: "link", style: LinkPagination, query: "per_page=2&sort=asc", wantBody: `[1,2]`, wantLink: `</items?page=2&per_page=2&sort=asc>; rel="next"`},
+		{name: "link_last", style: LinkPagination, query: "page=3&per_page=2", wantBody: `[5]`},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Paginator{Style: tt.style, URL: "/items"}
+			h := JSONHandler{
+				Method:       "GET",
+				PathFmt:      "/items",
+				ResponseCode: 200,
+				ResponseFn:   p.ResponseFn(func() []interface{} { return items }),
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/items?"+tt.query, nil))
+
+			wantCode := tt.wantCode
+			if wantCode == 0 {
+				wantCode = 200
+			}
+			if w.Code != wantCode {
+				t.Fatalf("want %v, but got %v: %v", wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantBody == "" {
+				return
+			}
+			if got := strings.TrimSpace(w.Body.String()); got != tt.wantBody {
+				t.Fatalf("want %v, but got %v", tt.wantBody, got)
+			}
+			if got := w.Header().Get("Link"); got != tt.wantLink {
+				t.Fatalf("want Link %v, but got %v", tt.wantLink, got)
+			}
+		})
+	}
+}
+
+func TestPaginator_maxPageSize(t *testing.T) {
+	p := Paginator{Style: OffsetPagination, PageSize: 2, MaxPageSize: 3}
+	items := []interface{}{1, 2, 3, 4, 5}
+
+	res, err := p.Paginate(items, map[string][]string{})
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if got := res.Body.(map[string]interface{})["limit"]; got != 2 {
+		t.Fatalf("want the default page size 2, but got %v", got)
+	}
+
+	res, err = p.Paginate(items, map[string][]string{"limit": {"100"}})
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if got := res.Body.(map[string]interface{})["limit"]; got != 3 {
+		t.Fatalf("want the max page size 3, but got %v", got)
+	}
+}
+
+func TestResource_pagination(t *testing.T) {
+	res := NewResource("/users", testUser{})
+	res.Pagination = &Paginator{Style: OffsetPagination, PageSize: 1}
+	res.Put("1", testUser{Name: "a"})
+	res.Put("2", testUser{Name: "b"})
+	h := NewMultipleHandler(res.Handlers())
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users?offset=1", nil))
+	var got struct {
+		Items []testUser
+		Total int
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if len(got.Items) != 1 || got.Items[0].Name != "b" || got.Total != 2 {
+		t.Fatalf("unexpected page: %+v", got)
+	}
+}
@@ -0,0 +1,170 @@
+package fakehttp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimitAlgorithm is an algorithm to limit the rate of requests.
+type RateLimitAlgorithm int
+
+const (
+	// TokenBucket allows bursts of up to Limit requests, and refills the
+	// bucket continuously at Limit requests per Window.
+	TokenBucket RateLimitAlgorithm = iota
+	// FixedWindow allows Limit requests in each Window, which starts at the
+	// first request after the previous one ends.
+	FixedWindow
+)
+
+// RateLimiter limits the rate of requests per client, responding with 429 Too
+// Many Requests and a Retry-After header when the limit is exceeded.  Every
+// response has the X-RateLimit-Limit, X-RateLimit-Remaining and
+// X-RateLimit-Reset headers.
+//
+// A RateLimiter can be attached to a JSONHandler, to the whole
+// MultipleHandler, or shared by several JSONHandlers to limit them as a
+// group.  It does not limit any request unless both Limit and Window are
+// positive.  It is safe for concurrent use.
+type RateLimiter struct {
+	// Algorithm is the algorithm to limit the rate.
+	Algorithm RateLimitAlgorithm
+	// Limit is the number of requests allowed per Window.
+	Limit int
+	// Window is the period in which Limit requests are allowed.
+	Window time.Duration
+	// Key returns the client of the request, which is limited separately
+	// from the other clients.  Defaults to ClientIPKey.
+	Key func(*http.Request) string
+
+	mu      sync.Mutex
+	clients map[string]*rateLimitClient
+}
+
+type rateLimitClient struct {
+	// tokens is the number of the remaining requests.
+	tokens float64
+	// last is the time when tokens was updated for TokenBucket, or the start
+	// of the window for FixedWindow.
+	last time.Time
+}
+
+// NewRateLimiter creates an instance of RateLimiter allowing limit requests
+// per window for each client IP address.  Both limit and window must be
+// positive.
+func NewRateLimiter(algorithm RateLimitAlgorithm, limit int, window time.Duration) (*RateLimiter, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("invalid rate limit: %v", limit)
+	}
+	if window <= 0 {
+		return nil, fmt.Errorf("invalid rate limit window: %v", window)
+	}
+	return &RateLimiter{Algorithm: algorithm, Limit: limit, Window: window}, nil
+}
+
+// ClientIPKey returns the IP address of the client sending the request.
+func ClientIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// HeaderKey returns a function returning the value of the HTTP request header
+// such as an API key, which can be used as RateLimiter.Key.
+func HeaderKey(name string) func(*http.Request) string {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// Reset forgets all the clients, so that their limits are restored.
+func (l *RateLimiter) Reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.clients = nil
+}
+
+// limit sets the X-RateLimit-* headers, and reports whether the request
+// exceeds the limit at the time of the clock.  In that case, it also sets the
+// Retry-After header.
+func (l *RateLimiter) limit(w http.ResponseWriter, r *http.Request, clock Clock) bool {
+	if l == nil || l.Limit <= 0 || l.Window <= 0 {
+		return false
+	}
+	key := ClientIPKey
+	if l.Key != nil {
+		key = l.Key
+	}
+	allowed, remaining, reset, retryAfter := l.take(key(r), now(clock))
+
+	header := w.Header()
+	header.Set("X-RateLimit-Limit", strconv.Itoa(l.Limit))
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	header.Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(reset)))
+	if !allowed {
+		header.Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+	}
+	return !allowed
+}
+
+// take consumes a request of the client.  It returns whether the request is
+// allowed, the number of the remaining requests, the duration until the
+// limit is fully restored, and the duration until the next request is
+// allowed.
+func (l *RateLimiter) take(key string, now time.Time) (bool, int, time.Duration, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.clients == nil {
+		l.clients = map[string]*rateLimitClient{}
+	}
+	c, ok := l.clients[key]
+	if !ok {
+		c = &rateLimitClient{tokens: float64(l.Limit), last: now}
+		l.clients[key] = c
+	}
+	limit := float64(l.Limit)
+
+	if l.Algorithm == FixedWindow {
+		if now.Sub(c.last) >= l.Window {
+			c.tokens, c.last = limit, now
+		}
+		reset := c.last.Add(l.Window).Sub(now)
+		if c.tokens < 1 {
+			return false, 0, reset, reset
+		}
+		c.tokens--
+		return true, int(c.tokens), reset, 0
+	}
+
+	// The rate at which the tokens are refilled per nanosecond.
+	rate := limit / float64(l.Window)
+	c.tokens = math.Min(limit, c.tokens+float64(now.Sub(c.last))*rate)
+	c.last = now
+	allowed := c.tokens >= 1
+	if allowed {
+		c.tokens--
+	}
+	reset := time.Duration((limit - c.tokens) / rate)
+	retryAfter := time.Duration(0)
+	if !allowed {
+		retryAfter = time.Duration((1 - c.tokens) / rate)
+	}
+	return allowed, int(c.tokens), reset, retryAfter
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// errRateLimited is the error of the responses to the requests exceeding the
+// limit.
+var errRateLimited = errors.New("rate limit exceeded")
@@ -0,0 +1,168 @@
+package fakehttp
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	type request struct {
+		after      time.Duration
+		want       int
+		remaining  string
+		retryAfter string
+	}
+	cases := []struct {
+		name      string
+		algorithm RateLimitAlgorithm
+		requests  []request
+	}{
+		{
+			name:      "token_bucket",
+			algorithm: TokenBucket,
+			requests: []request{
+				{after: 0, want: 200, remaining: "1"},
+				{after: 0, want: 200, remaining: "0"},
+				{after: 0, want: 429, remaining: "0", retryAfter: "30"},
+				{after: 30 * time.Second, want: 200, remaining: "0"},
+				{after: 60 * time.Second, want: 200, remaining: "1"},
+			},
+		},
+		{
+			name:      "fixed_window",
+			algorithm: FixedWindow,
+			requests: []request{
+				{after: 0, want: 200, remaining: "1"},
+				{after: 10 * time.Second, want: 200, remaining: "0"},
+				{after: 10 * time.Second, want: 429, remaining: "0", retryAfter: "40"},
+				{after: 40 * time.Second, want: 200, remaining: "1"},
+			},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+			l, err := NewRateLimiter(tt.algorithm, 2, time.Minute)
+			if err != nil {
+				t.Fatalf("should not be error, but: %v", err)
+			}
+			h := JSONHandler{
+				Method:       "GET",
+				PathFmt:      "/users",
+				ResponseCode: 200,
+				RateLimit:    l,
+				Clock:        clock,
+			}
+
+			for i, req := range tt.requests {
+				clock.Advance(req.after)
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users", nil))
+				if w.Code != req.want {
+					t.Fatalf("request %v: want %v, but got %v", i, req.want, w.Code)
+				}
+				if got := w.Header().Get("X-RateLimit-Limit"); got != "2" {
+					t.Fatalf("request %v: want limit 2, but got %v", i, got)
+				}
+				if got := w.Header().Get("X-RateLimit-Remaining"); got != req.remaining {
+					t.Fatalf("request %v: want remaining %v, but got %v", i, req.remaining, got)
+				}
+				if got := w.Header().Get("Retry-After"); got != req.retryAfter {
+					t.Fatalf("request %v: want Retry-After %v, but got %v", i, req.retryAfter, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiter_key(t *testing.T) {
+	l, err := NewRateLimiter(FixedWindow, 1, time.Minute)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	l.Key = HeaderKey("X-Api-Key")
+	h := NewMultipleHandler([]JSONHandler{
+		{Method: "GET", PathFmt: "/users", ResponseCode: 200},
+		{Method: "GET", PathFmt: "/groups", ResponseCode: 200},
+	})
+	h.RateLimit = l
+
+	cases := []struct {
+		target string
+		key    string
+		want   int
+	}{
+		{target: "/users", key: "a", want: 200},
+		{target: "/groups", key: "a", want: 429},
+		{target: "/groups", key: "b", want: 200},
+	}
+	for _, tt := range cases {
+		r := httptest.NewRequest("GET", "http://localhost"+tt.target, nil)
+		r.Header.Set("X-Api-Key", tt.key)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Fatalf("%v %v: want %v, but got %v", tt.target, tt.key, tt.want, w.Code)
+		}
+	}
+
+	l.Reset()
+	r := httptest.NewRequest("GET", "http://localhost/groups", nil)
+	r.Header.Set("X-Api-Key", "a")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Fatalf("want 200 after reset, but got %v", w.Code)
+	}
+}
+
+func TestNewRateLimiter_invalid(t *testing.T) {
+	cases := []struct {
+		name   string
+		limit  int
+		window time.Duration
+	}{
+		{name: "zero_limit", limit: 0, window: time.Minute},
+		{name: "negative_limit", limit: -1, window: time.Minute},
+		{name: "zero_window", limit: 5, window: 0},
+		{name: "negative_window", limit: 5, window: -time.Minute},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRateLimiter(TokenBucket, tt.limit, tt.window); err == nil {
+				t.Fatalf("should be error, but not")
+			}
+		})
+	}
+}
+
+func TestRateLimiter_invalid(t *testing.T) {
+	for _, l := range []*RateLimiter{{Limit: 5}, {Window: time.Minute}, {Algorithm: FixedWindow, Limit: 5}} {
+		h := JSONHandler{
+			Method:       "GET",
+			PathFmt:      "/users",
+			ResponseCode: 200,
+			RateLimit:    l,
+		}
+		for i := 0; i < 3; i++ {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users", nil))
+			if w.Code != 200 {
+				t.Fatalf("%+v: want 200, but got %v", l, w.Code)
+			}
+			if got := w.Header().Get("X-RateLimit-Remaining"); got != "" {
+				t.Fatalf("%+v: want no rate limit headers, but got %v", l, w.Header())
+			}
+		}
+	}
+}
+
+func TestClientIPKey(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://localhost/", nil)
+	r.RemoteAddr = "192.0.2.1:1234"
+	if got := ClientIPKey(r); got != "192.0.2.1" {
+		t.Fatalf("want 192.0.2.1, but got %v", got)
+	}
+}
@@ -0,0 +1,220 @@
+package fakehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// hopByHopHeaders are the HTTP headers which are not forwarded by Recorder.
+var hopByHopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Recorder is an HTTP handler that forwards requests to an upstream server and
+// records the request/response pairs as stubs.
+// Requests matching a recorded stub are served from it without being
+// forwarded.  Only JSON responses are recorded; the others are forwarded
+// every time.
+type Recorder struct {
+	// Client is used to forward requests.  If nil, a client that does not
+	// follow redirects is used, so that 3xx responses are recorded as is.
+	Client *http.Client
+
+	upstream *url.URL
+	mu       sync.Mutex
+	stubs    []Stub
+	handler  *MultipleHandler
+}
+
+// NewRecorder creates an instance of Recorder forwarding requests to the
+// upstream URL.
+func NewRecorder(upstream string) (*Recorder, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream URL: %v", upstream)
+	}
+	return &Recorder{
+		upstream: u,
+		handler:  NewMultipleHandler(nil),
+	}, nil
+}
+
+// Stubs returns the recorded stubs in the order recorded.
+func (rec *Recorder) Stubs() []Stub {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]Stub{}, rec.stubs...)
+}
+
+// Handler returns a MultipleHandler replaying the recorded stubs.
+func (rec *Recorder) Handler() *MultipleHandler {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return NewMultipleHandler(append([]JSONHandler{}, rec.handler.handlers...))
+}
+
+// SaveStubs writes the recorded stubs to the directory, one JSON file per
+// stub, so that they can be replayed with LoadStubs().
+func (rec *Recorder) SaveStubs(dir string) error {
+	for i, s := range rec.Stubs() {
+		b, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return err
+		}
+		name := fmt.Sprintf("%03d-%v%v.json", i+1, strings.ToLower(s.Request.Method), stubFileName(s.Request.Path))
+		if err := ioutil.WriteFile(filepath.Join(dir, name), append(b, '\n'), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+func stubFileName(p string) string {
+	return strings.TrimRight(unsafeFileNameChars.ReplaceAllString(p, "-"), "-")
+}
+
+// ServeHTTP is a method to implement http.Handler.
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mu.Lock()
+	h := *rec.handler
+	rec.mu.Unlock()
+	for _, handler := range h.handlers {
+		if ok, _ := handler.match(r); ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	reqBody, err := peekBody(r)
+	if err != nil {
+		h.errorResponse(w, err, http.StatusBadRequest)
+		return
+	}
+	res, resBody, err := rec.forward(r, reqBody)
+	if err != nil {
+		h.errorResponse(w, err, http.StatusBadGateway)
+		return
+	}
+
+	for k, vs := range res.Header {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(res.StatusCode)
+	w.Write(resBody)
+
+	if !json.Valid(resBody) && len(resBody) != 0 {
+		return
+	}
+	rec.record(r, reqBody, res, resBody)
+}
+
+// noRedirectClient is an http.Client that returns redirect responses instead
+// of following them.
+var noRedirectClient = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func (rec *Recorder) forward(r *http.Request, body []byte) (*http.Response, []byte, error) {
+	u := *rec.upstream
+	u.Path = strings.TrimRight(u.Path, "/") + r.URL.Path
+	u.RawQuery = r.URL.RawQuery
+
+	req, err := http.NewRequest(r.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(r.Context())
+	req.Header = r.Header.Clone()
+	for _, k := range hopByHopHeaders {
+		req.Header.Del(k)
+	}
+	// Let the transport negotiate and decode the compression, so that the
+	// response body can be validated and recorded as JSON.
+	req.Header.Del("Accept-Encoding")
+
+	client := rec.Client
+	if client == nil {
+		client = noRedirectClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, k := range hopByHopHeaders {
+		res.Header.Del(k)
+	}
+	return res, resBody, nil
+}
+
+func (rec *Recorder) record(r *http.Request, reqBody []byte, res *http.Response, resBody []byte) {
+	s := Stub{
+		Request: StubRequest{
+			Method: r.Method,
+			Path:   escapePathFmt(r.URL.Path),
+		},
+		Response: StubResponse{
+			Status: res.StatusCode,
+		},
+	}
+	if q := r.URL.Query(); len(q) != 0 {
+		s.Request.Query = map[string]string{}
+		for k := range q {
+			s.Request.Query[k] = q.Get(k)
+		}
+	}
+	if json.Valid(reqBody) {
+		s.Request.Body = json.RawMessage(reqBody)
+	}
+	for k := range res.Header {
+		switch k {
+		case "Content-Type", "Content-Length", "Content-Encoding", "Date":
+			continue
+		}
+		if s.Response.Headers == nil {
+			s.Response.Headers = map[string]string{}
+		}
+		s.Response.Headers[k] = res.Header.Get(k)
+	}
+	if len(resBody) != 0 {
+		s.Response.Body = json.RawMessage(resBody)
+	}
+
+	h, err := s.JSONHandler("", nil)
+	if err != nil {
+		return
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	rec.stubs = append(rec.stubs, s)
+	handlers := append([]JSONHandler{}, rec.handler.handlers...)
+	rec.handler = NewMultipleHandler(append(handlers, h))
+}
@@ -0,0 +1,165 @@
+package fakehttp
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/api/users/1":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Upstream", "yes")
+			w.Write([]byte(`{"id": 1, "query": "` + r.URL.Query().Get("q") + `"}`))
+		case "/api/text":
+			w.Write([]byte("plain text"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	rec, err := NewRecorder(upstream.URL + "/api")
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+
+	get := func(h http.Handler, target string) (int, string, string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost"+target, nil))
+		return w.Code, strings.TrimSpace(w.Body.String()), w.Header().Get("X-Upstream")
+	}
+
+	for i := 0; i < 2; i++ {
+		code, body, header := get(rec, "/users/1?q=a")
+		if code != 200 || body != `{"id": 1, "query": "a"}` && body != `{"id":1,"query":"a"}` || header != "yes" {
+			t.Fatalf("unexpected response: %v %v %v", code, body, header)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("want 1 upstream call, but got %v", calls)
+	}
+	get(rec, "/users/1?q=b")
+	get(rec, "/text")
+	get(rec, "/text")
+	if calls != 4 {
+		t.Fatalf("want 4 upstream calls, but got %v", calls)
+	}
+	if got := len(rec.Stubs()); got != 2 {
+		t.Fatalf("want 2 stubs, but got %v", got)
+	}
+
+	dir, err := ioutil.TempDir("", "fakehttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := rec.SaveStubs(dir); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+
+	upstream.Close()
+	for _, h := range []http.Handler{rec.Handler(), mustLoadStubs(t, dir)} {
+		code, body, header := get(h, "/users/1?q=b")
+		if code != 200 || body != `{"id":1,"query":"b"}` || header != "yes" {
+			t.Fatalf("unexpected replayed response: %v %v %v", code, body, header)
+		}
+	}
+}
+
+func mustLoadStubs(t *testing.T, dir string) *MultipleHandler {
+	t.Helper()
+	h, err := LoadStubs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestRecorder_redirect(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch r.URL.Path {
+		case "/old":
+			w.Header().Set("Location", "/new")
+			w.WriteHeader(http.StatusMovedPermanently)
+		case "/new":
+			w.Write([]byte(`{"id": 1}`))
+		}
+	}))
+	defer upstream.Close()
+
+	rec, err := NewRecorder(upstream.URL)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	w := httptest.NewRecorder()
+	rec.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/old", nil))
+	if w.Code != 301 || w.Header().Get("Location") != "/new" {
+		t.Fatalf("want the redirect, but got %v %v", w.Code, w.Header())
+	}
+	if calls != 1 {
+		t.Fatalf("want 1 upstream call, but got %v", calls)
+	}
+
+	stubs := rec.Stubs()
+	if len(stubs) != 1 {
+		t.Fatalf("want 1 stub, but got %v", len(stubs))
+	}
+	if s := stubs[0]; s.Request.Path != "/old" || s.Response.Status != 301 || s.Response.Headers["Location"] != "/new" {
+		t.Fatalf("unexpected stub: %+v", s)
+	}
+}
+
+func TestRecorder_gzip(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Write([]byte(`{"id": 1}`))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"id": 1}`))
+		zw.Close()
+	}))
+	defer upstream.Close()
+
+	rec, err := NewRecorder(upstream.URL)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	// http.Get sends Accept-Encoding: gzip.
+	res, err := http.Get(srv.URL + "/users/1")
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	b, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != 200 || string(b) != `{"id": 1}` {
+		t.Fatalf("unexpected response: %v %q", res.StatusCode, b)
+	}
+
+	stubs := rec.Stubs()
+	if len(stubs) != 1 {
+		t.Fatalf("want 1 stub, but got %v", len(stubs))
+	}
+	if s := stubs[0]; !reflect.DeepEqual(s.Response.Body, json.RawMessage(`{"id": 1}`)) || s.Response.Headers["Content-Encoding"] != "" {
+		t.Fatalf("unexpected stub: %+v", s)
+	}
+}
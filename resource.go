@@ -0,0 +1,352 @@
+package fakehttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resource is an in-memory REST resource, which serves a collection of items
+// with CRUD semantics:
+//
+//	GET    {BasePath}      lists the items
+//	POST   {BasePath}      creates an item, responding with 201
+//	GET    {BasePath}/{id} gets the item
+//	PUT    {BasePath}/{id} replaces the item
+//	DELETE {BasePath}/{id} deletes the item, responding with 204
+//
+// A request to a missing item is responded with 404, and a creation of an
+// item whose ID already exists with 409.  The responses have an ETag, so that
+// clients can send conditional requests such as PUT with If-Match.  The items
+// can be seeded and inspected with Put(), Get() and List().  It is safe for
+// concurrent use.
+type Resource struct {
+	// BasePath is the URL path of the collection such as `/users`.  It may
+	// contain patterns of path.Match() such as `/groups/*/users`.
+	BasePath string
+	// IDField is the JSON field name of the item which holds the ID.  The ID
+	// is generated if the field is empty on creation, and set from the URL
+	// path on replacement.  The field must be a string or an integer.
+	// Defaults to "id".
+	IDField string
+	// Pagination paginates the list of the items if it is not nil.
+	Pagination *Paginator
+
+	itemType reflect.Type
+
+	mu     sync.Mutex
+	items  map[string]interface{}
+	ids    []string
+	nextID int
+}
+
+// NewResource creates an instance of Resource serving items of the same type
+// as item under the base path.
+func NewResource(basePath string, item interface{}) *Resource {
+	t := reflect.TypeOf(item)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return &Resource{BasePath: basePath, IDField: "id", itemType: t}
+}
+
+// Handlers returns the JSONHandlers serving the resource.
+func (res *Resource) Handlers() []JSONHandler {
+	collection := res.BasePath
+	item := res.BasePath + "/*"
+	newItem := func() interface{} {
+		return reflect.New(res.itemType).Interface()
+	}
+	validators := func(params []string) (string, time.Time) {
+		return res.etag(params[len(params)-1]), time.Time{}
+	}
+	return []JSONHandler{
+		{
+			Method:       http.MethodGet,
+			PathFmt:      collection,
+			ResponseCode: http.StatusOK,
+			Conditional:  true,
+			ResponseFn: func(_ interface{}, _ []string, query url.Values) (interface{}, error) {
+				if res.Pagination != nil {
+					return res.Pagination.Paginate(res.List(), query)
+				}
+				return res.List(), nil
+			},
+		},
+		{
+			Method:         http.MethodPost,
+			PathFmt:        collection,
+			NewRequestBody: newItem,
+			ResponseCode:   http.StatusCreated,
+			ResponseFn: func(body interface{}, _ []string, _ url.Values) (interface{}, error) {
+				return res.create(body)
+			},
+		},
+		{
+			Method:       http.MethodGet,
+			PathFmt:      item,
+			ResponseCode: http.StatusOK,
+			Conditional:  true,
+			Validators:   validators,
+			ResponseFn: func(_ interface{}, params []string, _ url.Values) (interface{}, error) {
+				id := params[len(params)-1]
+				v, ok := res.Get(id)
+				if !ok {
+					return nil, res.notFound(id)
+				}
+				return v, nil
+			},
+		},
+		{
+			Method:         http.MethodPut,
+			PathFmt:        item,
+			NewRequestBody: newItem,
+			ResponseCode:   http.StatusOK,
+			Conditional:    true,
+			Validators:     validators,
+			ResponseFn: func(body interface{}, params []string, _ url.Values) (interface{}, error) {
+				return res.replace(params[len(params)-1], body)
+			},
+		},
+		{
+			Method:       http.MethodDelete,
+			PathFmt:      item,
+			ResponseCode: http.StatusNoContent,
+			Conditional:  true,
+			Validators:   validators,
+			ResponseFn: func(_ interface{}, params []string, _ url.Values) (interface{}, error) {
+				id := params[len(params)-1]
+				if !res.Delete(id) {
+					return nil, res.notFound(id)
+				}
+				return nil, nil
+			},
+		},
+	}
+}
+
+// Register adds the JSONHandlers serving the resource to h.
+func (res *Resource) Register(h *MultipleHandler) {
+	for _, handler := range res.Handlers() {
+		h.AddHandler(handler)
+	}
+}
+
+// Put stores the item with the ID, replacing the existing one.  The ID field
+// of the item is set to id.
+func (res *Resource) Put(id string, item interface{}) error {
+	v := reflect.ValueOf(item)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Type() != res.itemType {
+		return fmt.Errorf("resource: want %v, got %v", res.itemType, v.Type())
+	}
+	p := reflect.New(res.itemType)
+	p.Elem().Set(v)
+	if err := res.setID(p.Elem(), id); err != nil {
+		return err
+	}
+
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	res.put(id, p.Elem().Interface())
+	return nil
+}
+
+// Get returns the item with the ID, and reports whether it exists.
+func (res *Resource) Get(id string) (interface{}, bool) {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	v, ok := res.items[id]
+	return v, ok
+}
+
+// List returns all the items in the order created.
+func (res *Resource) List() []interface{} {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	ret := make([]interface{}, 0, len(res.ids))
+	for _, id := range res.ids {
+		ret = append(ret, res.items[id])
+	}
+	return ret
+}
+
+// Delete removes the item with the ID, and reports whether it existed.
+func (res *Resource) Delete(id string) bool {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	if _, ok := res.items[id]; !ok {
+		return false
+	}
+	delete(res.items, id)
+	for i, v := range res.ids {
+		if v == id {
+			res.ids = append(res.ids[:i], res.ids[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Reset removes all the items and restarts the ID generation.
+func (res *Resource) Reset() {
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	res.items = nil
+	res.ids = nil
+	res.nextID = 0
+}
+
+func (res *Resource) create(body interface{}) (interface{}, error) {
+	v := reflect.ValueOf(body).Elem()
+	id := res.getID(v)
+
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	if id == "" {
+		for {
+			res.nextID++
+			id = strconv.Itoa(res.nextID)
+			if _, ok := res.items[id]; !ok {
+				break
+			}
+		}
+		if err := res.setID(v, id); err != nil {
+			return nil, &StatusError{StatusCode: http.StatusInternalServerError, Err: err}
+		}
+	} else if _, ok := res.items[id]; ok {
+		return nil, &StatusError{StatusCode: http.StatusConflict, Err: fmt.Errorf("%v already exists", id)}
+	}
+	res.put(id, v.Interface())
+	return v.Interface(), nil
+}
+
+func (res *Resource) replace(id string, body interface{}) (interface{}, error) {
+	v := reflect.ValueOf(body).Elem()
+	if err := res.setID(v, id); err != nil {
+		return nil, err
+	}
+
+	res.mu.Lock()
+	defer res.mu.Unlock()
+	if _, ok := res.items[id]; !ok {
+		return nil, res.notFound(id)
+	}
+	res.items[id] = v.Interface()
+	return v.Interface(), nil
+}
+
+// put stores the item.  res.mu must be locked.
+func (res *Resource) put(id string, item interface{}) {
+	if res.items == nil {
+		res.items = map[string]interface{}{}
+	}
+	if _, ok := res.items[id]; !ok {
+		res.ids = append(res.ids, id)
+	}
+	res.items[id] = item
+}
+
+// etag returns the ETag of the item, or an empty string if it does not exist.
+func (res *Resource) etag(id string) string {
+	v, ok := res.Get(id)
+	if !ok {
+		return ""
+	}
+	b, err := encodeJSON(v)
+	if err != nil {
+		return ""
+	}
+	return generateETag(b)
+}
+
+func (res *Resource) notFound(id string) error {
+	return &StatusError{StatusCode: http.StatusNotFound, Err: fmt.Errorf("%v not found", id)}
+}
+
+func (res *Resource) idName() string {
+	if res.IDField == "" {
+		return "id"
+	}
+	return res.IDField
+}
+
+// idField returns the field of the struct v holding the ID, or an invalid
+// value if there is no such field.
+func (res *Resource) idField(v reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Type().Field(i)
+		tag := strings.SplitN(f.Tag.Get("json"), ",", 2)[0]
+		if tag == res.idName() || (tag == "" && f.Name == res.idName()) {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// getID returns the ID of the item v, which is a struct or a map with string
+// keys, or an empty string if the ID is not set.
+func (res *Resource) getID(v reflect.Value) string {
+	if v.Kind() == reflect.Map {
+		if v.IsNil() || v.Type().Key().Kind() != reflect.String {
+			return ""
+		}
+		id := v.MapIndex(reflect.ValueOf(res.idName()).Convert(v.Type().Key()))
+		if !id.IsValid() || id.IsZero() {
+			return ""
+		}
+		return fmt.Sprint(id.Interface())
+	}
+	f := res.idField(v)
+	if !f.IsValid() || f.IsZero() {
+		return ""
+	}
+	return fmt.Sprint(f.Interface())
+}
+
+// setID sets the ID of the item v, which must be addressable.
+func (res *Resource) setID(v reflect.Value, id string) error {
+	if v.Kind() == reflect.Map {
+		if v.Type().Key().Kind() != reflect.String || !reflect.TypeOf(id).AssignableTo(v.Type().Elem()) {
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		v.SetMapIndex(reflect.ValueOf(res.idName()).Convert(v.Type().Key()), reflect.ValueOf(id))
+		return nil
+	}
+	f := res.idField(v)
+	if !f.IsValid() {
+		return nil
+	}
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(id)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return fmt.Errorf("resource: invalid ID %v: %w", id, err)
+		}
+		f.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return fmt.Errorf("resource: invalid ID %v: %w", id, err)
+		}
+		f.SetUint(n)
+	default:
+		return fmt.Errorf("resource: unsupported ID type %v", f.Type())
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package fakehttp
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestResource(t *testing.T) {
+	res := NewResource("/users", testUser{})
+	if err := res.Put("10", testUser{Name: "seeded"}); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	h := NewMultipleHandler(nil)
+	res.Register(h)
+
+	cases := []struct {
+		method   string
+		target   string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{method: "GET", target: "/users/10", wantCode: 200, wantBody: `{"id":10,"name":"seeded"}`},
+		{method: "POST", target: "/users", body: `{"name":"created"}`, wantCode: 201, wantBody: `{"id":1,"name":"created"}`},
+		{method: "POST", target: "/users", body: `{"id":10,"name":"duplicated"}`, wantCode: 409},
+		{method: "GET", target: "/users", wantCode: 200, wantBody: `[{"id":10,"name":"seeded"},{"id":1,"name":"created"}]`},
+		{method: "PUT", target: "/users/1", body: `{"name":"updated"}`, wantCode: 200, wantBody: `{"id":1,"name":"updated"}`},
+		{method: "PUT", target: "/users/2", body: `{"name":"missing"}`, wantCode: 404},
+		{method: "DELETE", target: "/users/10", wantCode: 204},
+		{method: "DELETE", target: "/users/10", wantCode: 404},
+		{method: "GET", target: "/users/10", wantCode: 404},
+	}
+	for _, tt := range cases {
+		r := httptest.NewRequest(tt.method, "http://localhost"+tt.target, strings.NewReader(tt.body))
+		if tt.body != "" {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != tt.wantCode {
+			t.Fatalf("%v %v: want %v, but got %v: %v", tt.method, tt.target, tt.wantCode, w.Code, w.Body.String())
+		}
+		if tt.wantBody == "" {
+			continue
+		}
+		if got := strings.TrimSpace(w.Body.String()); got != tt.wantBody {
+			t.Fatalf("%v %v: want %v, but got %v", tt.method, tt.target, tt.wantBody, got)
+		}
+	}
+
+	want := []interface{}{testUser{ID: 1, Name: "updated"}}
+	if got := res.List(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, but got %v", want, got)
+	}
+
+	res.Reset()
+	if got := res.List(); len(got) != 0 {
+		t.Fatalf("want empty after reset, but got %v", got)
+	}
+}
+
+func TestResource_Put_invalidType(t *testing.T) {
+	res := NewResource("/users", testUser{})
+	if err := res.Put("1", map[string]interface{}{}); err == nil {
+		t.Fatalf("should be error, but not")
+	}
+}
+
+func TestResource_map(t *testing.T) {
+	res := NewResource("/groups/*/users", map[string]interface{}{})
+	h := NewMultipleHandler(res.Handlers())
+
+	r := httptest.NewRequest("POST", "http://localhost/groups/a/users", strings.NewReader(`{"name":"created"}`))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 201 {
+		t.Fatalf("want 201, but got %v: %v", w.Code, w.Body.String())
+	}
+
+	got, ok := res.Get("1")
+	if !ok {
+		t.Fatalf("want the created item, but not found")
+	}
+	if want := map[string]interface{}{"id": "1", "name": "created"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v, but got %v", want, got)
+	}
+}
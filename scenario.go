@@ -0,0 +1,87 @@
+package fakehttp
+
+import (
+	"sync"
+)
+
+// ScenarioStarted is the initial state of a Scenario.
+const ScenarioStarted = "Started"
+
+// Scenario is a named state machine shared by JSONHandlers, which makes it
+// possible to return different responses to the same request, e.g. `pending`
+// for the first polling request and `done` for the later ones.
+// It is safe for concurrent use.
+type Scenario struct {
+	// Name is the name of the scenario.
+	Name string
+
+	mu    sync.Mutex
+	state string
+}
+
+// NewScenario creates an instance of Scenario in the ScenarioStarted state.
+func NewScenario(name string) *Scenario {
+	return &Scenario{Name: name, state: ScenarioStarted}
+}
+
+// State returns the current state.
+func (s *Scenario) State() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// SetState changes the current state.
+func (s *Scenario) SetState(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// Reset changes the current state to ScenarioStarted.
+func (s *Scenario) Reset() {
+	s.SetState(ScenarioStarted)
+}
+
+// Scenarios is a set of Scenarios looked up by name.
+// The zero value is an empty set ready to use, and it is safe for concurrent
+// use.
+type Scenarios struct {
+	mu        sync.Mutex
+	scenarios map[string]*Scenario
+}
+
+// Get returns the Scenario with the name, creating it if it does not exist.
+func (ss *Scenarios) Get(name string) *Scenario {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.scenarios == nil {
+		ss.scenarios = map[string]*Scenario{}
+	}
+	s, ok := ss.scenarios[name]
+	if !ok {
+		s = NewScenario(name)
+		ss.scenarios[name] = s
+	}
+	return s
+}
+
+// States returns the current states keyed by the scenario names.
+func (ss *Scenarios) States() map[string]string {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	ret := map[string]string{}
+	for name, s := range ss.scenarios {
+		ret[name] = s.State()
+	}
+	return ret
+}
+
+// Reset changes the states of all the scenarios to ScenarioStarted.
+func (ss *Scenarios) Reset() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for _, s := range ss.scenarios {
+		s.Reset()
+	}
+}
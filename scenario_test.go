@@ -0,0 +1,94 @@
+package fakehttp
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestMultipleHandler_ServeHTTP_scenario(t *testing.T) {
+	scenarios := &Scenarios{}
+	job := scenarios.Get("job")
+	respond := func(status string) func(interface{}, []string, url.Values) (interface{}, error) {
+		return func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+			return map[string]string{"status": status}, nil
+		}
+	}
+
+	h := NewMultipleHandler([]JSONHandler{
+		{
+			Method:        "GET",
+			PathFmt:       "/jobs/1",
+			ResponseCode:  200,
+			ResponseFn:    respond("pending"),
+			Scenario:      job,
+			RequiredState: ScenarioStarted,
+			NewState:      "polled",
+		},
+		{
+			Method:        "GET",
+			PathFmt:       "/jobs/1",
+			ResponseCode:  200,
+			ResponseFn:    respond("pending"),
+			Scenario:      job,
+			RequiredState: "polled",
+			NewState:      "done",
+		},
+		{
+			Method:        "GET",
+			PathFmt:       "/jobs/1",
+			ResponseCode:  200,
+			ResponseFn:    respond("done"),
+			Scenario:      job,
+			RequiredState: "done",
+		},
+	})
+	h.Scenarios = scenarios
+
+	get := func() string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/jobs/1", nil))
+		return w.Body.String()
+	}
+
+	want := []string{"pending", "pending", "done", "done"}
+	for i, status := range want {
+		if got := get(); got != `{"status":"`+status+`"}`+"\n" {
+			t.Fatalf("request %v: want %v, but got %v", i, status, got)
+		}
+	}
+	if got := scenarios.States(); !reflect.DeepEqual(got, map[string]string{"job": "done"}) {
+		t.Fatalf("want done, but got %v", got)
+	}
+
+	scenarios.Reset()
+	if got := job.State(); got != ScenarioStarted {
+		t.Fatalf("want %v, but got %v", ScenarioStarted, got)
+	}
+	if got := get(); got != `{"status":"pending"}`+"\n" {
+		t.Fatalf("want pending after reset, but got %v", got)
+	}
+}
+
+func TestStub_JSONHandler_scenario(t *testing.T) {
+	s := Stub{
+		Request:  StubRequest{Method: "GET", Path: "/jobs/1"},
+		Scenario: "job",
+		NewState: "done",
+	}
+	if _, err := s.JSONHandler("", nil); err == nil {
+		t.Fatalf("should be error, but not")
+	}
+
+	scenarios := &Scenarios{}
+	h, err := s.JSONHandler("", scenarios)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://localhost/jobs/1", nil))
+	if got := scenarios.Get("job").State(); got != "done" {
+		t.Fatalf("want done, but got %v", got)
+	}
+}
@@ -0,0 +1,95 @@
+package fakehttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Response is a static HTTP response.
+type Response struct {
+	// StatusCode is an HTTP response code.  If 0, JSONHandler.ResponseCode is
+	// used.
+	StatusCode int
+	// Header is added to the header of the HTTP response.
+	Header http.Header
+	// Body is JSON encoded as the response body.  No body is written if nil.
+	Body interface{}
+}
+
+// SequencePolicy specifies what a Sequence does when all the responses are
+// consumed.
+type SequencePolicy int
+
+const (
+	// RepeatLast repeats the last response.
+	RepeatLast SequencePolicy = iota
+	// Cycle starts over from the first response.
+	Cycle
+	// FailWhenExhausted returns a 500 error response and fails the test if
+	// Sequence.T is set.
+	FailWhenExhausted
+)
+
+// Sequence is an ordered list of responses consumed one per request, e.g. to
+// test retries against 503, 503 and 200.  It is safe for concurrent use.
+type Sequence struct {
+	// Responses are returned in order.
+	Responses []Response
+	// Policy specifies what to do when all the responses are consumed.
+	Policy SequencePolicy
+	// T is notified of an error when the sequence is exhausted with the
+	// FailWhenExhausted policy.  *testing.T can be specified.
+	T interface {
+		Errorf(format string, args ...interface{})
+	}
+
+	mu    sync.Mutex
+	calls int
+}
+
+// NewSequence creates an instance of Sequence.
+func NewSequence(policy SequencePolicy, responses ...Response) *Sequence {
+	return &Sequence{Responses: responses, Policy: policy}
+}
+
+// Calls returns the number of the consumed responses, including the requests
+// made after the sequence was exhausted.
+func (s *Sequence) Calls() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// Reset starts the sequence over from the first response.
+func (s *Sequence) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = 0
+}
+
+func (s *Sequence) next() (Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Responses) == 0 {
+		return Response{}, errors.New("sequence has no responses")
+	}
+
+	i := s.calls
+	s.calls++
+	if i < len(s.Responses) {
+		return s.Responses[i], nil
+	}
+	switch s.Policy {
+	case Cycle:
+		return s.Responses[i%len(s.Responses)], nil
+	case FailWhenExhausted:
+		err := fmt.Errorf("sequence exhausted: %v responses, got request %v", len(s.Responses), i+1)
+		if s.T != nil {
+			s.T.Errorf("fakehttp: %v", err)
+		}
+		return Response{}, err
+	}
+	return s.Responses[len(s.Responses)-1], nil
+}
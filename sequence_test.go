@@ -0,0 +1,107 @@
+package fakehttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type fakeT struct {
+	errors []string
+}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestJSONHandler_ServeHTTP_sequence(t *testing.T) {
+	responses := []Response{
+		{StatusCode: 503, Header: http.Header{"Retry-After": {"1"}}},
+		{StatusCode: 503},
+		{Body: map[string]string{"status": "ok"}},
+	}
+
+	cases := []struct {
+		policy SequencePolicy
+		want   []int
+		errors int
+	}{
+		{policy: RepeatLast, want: []int{503, 503, 200, 200, 200}, errors: 0},
+		{policy: Cycle, want: []int{503, 503, 200, 503, 503}, errors: 0},
+		{policy: FailWhenExhausted, want: []int{503, 503, 200, 500, 500}, errors: 2},
+	}
+
+	for _, tt := range cases {
+		t.Run(fmt.Sprint(tt.policy), func(t *testing.T) {
+			ft := &fakeT{}
+			seq := NewSequence(tt.policy, responses...)
+			seq.T = ft
+			h := JSONHandler{
+				Method:       "GET",
+				PathFmt:      "/users",
+				ResponseCode: 200,
+				Sequence:     seq,
+			}
+
+			got := []int{}
+			for range tt.want {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users", nil))
+				got = append(got, w.Code)
+				if w.Code == 503 && len(got) == 1 && w.Header().Get("Retry-After") != "1" {
+					t.Fatalf("want Retry-After header, but got %v", w.Header())
+				}
+				if w.Code == 200 && w.Body.String() != `{"status":"ok"}`+"\n" {
+					t.Fatalf("unexpected body: %v", w.Body.String())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("want %v, but got %v", tt.want, got)
+			}
+			if len(ft.errors) != tt.errors {
+				t.Fatalf("want %v errors, but got %v", tt.errors, ft.errors)
+			}
+			if seq.Calls() != len(tt.want) {
+				t.Fatalf("want %v calls, but got %v", len(tt.want), seq.Calls())
+			}
+
+			seq.Reset()
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users", nil))
+			if w.Code != 503 {
+				t.Fatalf("want 503 after reset, but got %v", w.Code)
+			}
+		})
+	}
+}
+
+func TestStub_JSONHandler_responses(t *testing.T) {
+	s := Stub{
+		Request: StubRequest{Method: "GET", Path: "/users"},
+		Responses: []StubResponse{
+			{Status: 503},
+			{Body: map[string]interface{}{"id": 1}},
+		},
+		Exhausted: "cycle",
+	}
+	h, err := s.JSONHandler("", nil)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+
+	want := []int{503, 200, 503}
+	for i, code := range want {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/users", nil))
+		if w.Code != code {
+			t.Fatalf("request %v: want %v, but got %v", i, code, w.Code)
+		}
+	}
+
+	s.Exhausted = "never"
+	if _, err := s.JSONHandler("", nil); err == nil {
+		t.Fatalf("should be error, but not")
+	}
+}
@@ -0,0 +1,159 @@
+package fakehttp
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// ID is the event ID, which the client sends back in the Last-Event-ID
+	// header when it reconnects.  It is omitted if it is an empty string.
+	ID string
+	// Event is the event type.  It is omitted if it is an empty string.
+	Event string
+	// Data is the event data.  A multi-line string is sent as multiple data
+	// fields.
+	Data string
+	// Retry is the reconnection time sent to the client.  It is omitted if it
+	// is not positive.
+	Retry time.Duration
+	// Delay is how long to wait before sending the event.
+	Delay time.Duration
+}
+
+// String returns the event in the text/event-stream format.
+func (e Event) String() string {
+	var b strings.Builder
+	if e.ID != "" {
+		fmt.Fprintf(&b, "id: %v\n", e.ID)
+	}
+	if e.Event != "" {
+		fmt.Fprintf(&b, "event: %v\n", e.Event)
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", e.Retry.Milliseconds())
+	}
+	for _, line := range strings.Split(e.Data, "\n") {
+		fmt.Fprintf(&b, "data: %v\n", line)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// SSEHandler is a mock of an HTTP handler that streams Server-Sent Events.
+// The HTTP request is matched in the same way as JSONHandler.  Each event is
+// flushed as soon as it is written.
+type SSEHandler struct {
+	// PathFmt is a pattern of URL paths to bind a handler to.  See
+	// JSONHandler.PathFmt.
+	PathFmt string
+	// PathRegexp is a regular expression of URL paths to bind a handler to.
+	// See JSONHandler.PathRegexp.
+	PathRegexp *regexp.Regexp
+	// Method is an HTTP request method.  Skip the HTTP method check if it is an
+	// empty string.
+	Method string
+	// Matchers are additional conditions that the HTTP request must satisfy.
+	Matchers []Matcher
+	// ResponseHeader is added to the header of the HTTP response.
+	ResponseHeader http.Header
+	// Events are sent in order.  If the request has the Last-Event-ID header,
+	// the events up to the one with the ID are skipped to resume the stream.
+	Events []Event
+	// EventCh feeds the events sent after Events if it is not nil.  The
+	// stream ends when it is closed or the client disconnects.  Otherwise the
+	// stream ends after Events.
+	EventCh <-chan Event
+	// Clock is used to wait for Event.Delay.  If nil, the system clock is
+	// used, or MultipleHandler.Clock when served by MultipleHandler.
+	Clock Clock
+	// ErrResponseFn specifies how to return an error response.  See
+	// JSONHandler.ErrResponseFn.
+	ErrResponseFn func(http.ResponseWriter, error, int)
+}
+
+// jsonHandler returns the JSONHandler that matches the same HTTP requests as
+// h, and serves them with h.
+func (h SSEHandler) jsonHandler() JSONHandler {
+	return JSONHandler{
+		PathFmt:       h.PathFmt,
+		PathRegexp:    h.PathRegexp,
+		Method:        h.Method,
+		Matchers:      h.Matchers,
+		ErrResponseFn: h.ErrResponseFn,
+		serve: func(w http.ResponseWriter, r *http.Request, clock Clock) {
+			if h.Clock == nil {
+				h.Clock = clock
+			}
+			h.stream(w, r)
+		},
+	}
+}
+
+// ServeHTTP is a method to implement http.Handler.
+func (h SSEHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.jsonHandler().ServeHTTP(w, r)
+}
+
+func (h SSEHandler) stream(w http.ResponseWriter, r *http.Request) {
+	for k, vs := range h.ResponseHeader {
+		for _, v := range vs {
+			w.Header().Add(k, v)
+		}
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flush(w)
+
+	send := func(e Event) bool {
+		if !sleep(r.Context(), h.Clock, e.Delay) {
+			return false
+		}
+		if _, err := w.Write([]byte(e.String())); err != nil {
+			return false
+		}
+		flush(w)
+		return true
+	}
+
+	events := h.Events
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		for i, e := range events {
+			if e.ID == id {
+				events = events[i+1:]
+				break
+			}
+		}
+	}
+	for _, e := range events {
+		if !send(e) {
+			return
+		}
+	}
+
+	if h.EventCh == nil {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-h.EventCh:
+			if !ok || !send(e) {
+				return
+			}
+		}
+	}
+}
+
+// AddSSEHandler adds an SSEHandler to mock.
+// The SSEHandler argument specifies that the Method and PathFmt fields must
+// not be empty strings.  PathFmt may be empty if PathRegexp is specified.
+func (h *MultipleHandler) AddSSEHandler(handler SSEHandler) {
+	h.AddHandler(handler.jsonHandler())
+}
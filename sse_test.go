@@ -0,0 +1,146 @@
+package fakehttp
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvent_String(t *testing.T) {
+	e := Event{ID: "1", Event: "update", Data: "a\nb", Retry: 3 * time.Second}
+	want := "id: 1\nevent: update\nretry: 3000\ndata: a\ndata: b\n\n"
+	if got := e.String(); got != want {
+		t.Fatalf("want %q, but got %q", want, got)
+	}
+}
+
+func TestSSEHandler_ServeHTTP(t *testing.T) {
+	h := SSEHandler{
+		Method:  "GET",
+		PathFmt: "/events",
+		Events: []Event{
+			{ID: "1", Data: "a"},
+			{ID: "2", Data: "b"},
+			{ID: "3", Data: "c"},
+		},
+	}
+
+	cases := []struct {
+		name        string
+		lastEventID string
+		want        string
+	}{
+		{name: "all", want: "id: 1\ndata: a\n\nid: 2\ndata: b\n\nid: 3\ndata: c\n\n"},
+		{name: "resume", lastEventID: "2", want: "id: 3\ndata: c\n\n"},
+		{name: "unknown_id", lastEventID: "x", want: "id: 1\ndata: a\n\nid: 2\ndata: b\n\nid: 3\ndata: c\n\n"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://localhost/events", nil)
+			if tt.lastEventID != "" {
+				r.Header.Set("Last-Event-ID", tt.lastEventID)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != 200 {
+				t.Fatalf("want 200, but got %v", w.Code)
+			}
+			if got := w.Header().Get("Content-Type"); got != "text/event-stream" {
+				t.Fatalf("want text/event-stream, but got %v", got)
+			}
+			if !w.Flushed {
+				t.Fatalf("want flushed, but not")
+			}
+			if got := w.Body.String(); got != tt.want {
+				t.Fatalf("want %q, but got %q", tt.want, got)
+			}
+		})
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "http://localhost/events", nil))
+	if w.Code != 404 {
+		t.Fatalf("want 404, but got %v", w.Code)
+	}
+}
+
+func TestMultipleHandler_AddSSEHandler(t *testing.T) {
+	ch := make(chan Event)
+	h := NewMultipleHandler(nil)
+	h.Journal = &Journal{}
+	h.AddSSEHandler(SSEHandler{
+		Method:  "GET",
+		PathFmt: "/events",
+		Events:  []Event{{Event: "hello", Data: "scripted"}},
+		EventCh: ch,
+	})
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	defer res.Body.Close()
+	r := bufio.NewReader(res.Body)
+	readEvent := func() string {
+		var b strings.Builder
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("should not be error, but: %v", err)
+			}
+			b.WriteString(line)
+			if line == "\n" {
+				return b.String()
+			}
+		}
+	}
+
+	if got := readEvent(); got != "event: hello\ndata: scripted\n\n" {
+		t.Fatalf("unexpected event: %q", got)
+	}
+	// The event is received before the stream ends only if it is flushed.
+	ch <- Event{ID: "1", Data: "fed"}
+	if got := readEvent(); got != "id: 1\ndata: fed\n\n" {
+		t.Fatalf("unexpected event: %q", got)
+	}
+	close(ch)
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Fatalf("want the end of the stream, but not")
+	}
+
+	entries := h.Journal.Entries()
+	if len(entries) != 1 || !entries[0].Matched || entries[0].StatusCode != 200 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestSSEHandler_delay(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	h := NewMultipleHandler(nil)
+	h.Clock = clock
+	h.AddSSEHandler(SSEHandler{
+		Method:  "GET",
+		PathFmt: "/events",
+		Events:  []Event{{Data: "late", Delay: time.Minute}},
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/events", nil))
+		done <- w
+	}()
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	clock.Advance(time.Minute)
+	if got := (<-done).Body.String(); got != "data: late\n\n" {
+		t.Fatalf("unexpected body: %q", got)
+	}
+}
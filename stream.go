@@ -0,0 +1,170 @@
+package fakehttp
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// StreamFormat is the format of a streamed response.
+type StreamFormat int
+
+// The formats of streamed responses.
+const (
+	// NDJSON writes each item as a line of JSON, with the Content-Type
+	// application/x-ndjson.
+	NDJSON StreamFormat = iota
+	// JSONArray writes the items as the elements of a JSON array, with the
+	// Content-Type application/json.
+	JSONArray
+)
+
+// Stream streams the response body item by item, flushing each one.
+type Stream struct {
+	// Format is the format of the response body.
+	Format StreamFormat
+	// Items yields the items of the response in order by calling yield,
+	// which returns an error if the stream is aborted, e.g. because the
+	// client disconnected or Fault is injected.  The arguments are the same
+	// as those of JSONHandler.ResponseFn.  If an error is returned before any
+	// item is yielded, an error response is returned as for ResponseFn.
+	// Otherwise the connection is closed to make the body incomplete.
+	// StreamChannel() and StreamSlice() can be used for simple cases.  500
+	// Internal Server Error is returned if it is nil.
+	Items func(body interface{}, params []string, query url.Values, yield func(interface{}) error) error
+	// Delay delays each item if it is not nil.
+	Delay Delay
+	// Fault is injected after FailAfter items are written.
+	// FaultTruncatedBody writes half of the next item and closes the
+	// connection, and FaultMalformedJSON writes half of the next item and
+	// ends the body.  The other faults reset the connection.
+	Fault Fault
+	// FailAfter is the number of items written before Fault is injected.  If
+	// the stream ends with exactly FailAfter items, the connection is reset.
+	FailAfter int
+}
+
+// errStreamAborted is returned by the yield function of Stream.Items after the
+// stream is aborted.
+var errStreamAborted = errors.New("stream aborted")
+
+// StreamChannel returns a Stream.Items function that yields the items received
+// from ch until it is closed.  The channel is shared by all the requests.
+func StreamChannel(ch <-chan interface{}) func(interface{}, []string, url.Values, func(interface{}) error) error {
+	return func(_ interface{}, _ []string, _ url.Values, yield func(interface{}) error) error {
+		for item := range ch {
+			if err := yield(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// StreamSlice returns a Stream.Items function that yields the items.
+func StreamSlice(items ...interface{}) func(interface{}, []string, url.Values, func(interface{}) error) error {
+	return func(_ interface{}, _ []string, _ url.Values, yield func(interface{}) error) error {
+		for _, item := range items {
+			if err := yield(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// serve writes the streamed response to the request matched by h.
+func (s *Stream) serve(w http.ResponseWriter, r *http.Request, h JSONHandler, params []string) {
+	contentType := "application/x-ndjson"
+	if s.Format == JSONArray {
+		contentType = "application/json"
+	}
+
+	started, aborted, n := false, false, 0
+	start := func() {
+		for k, vs := range h.ResponseHeader {
+			for _, v := range vs {
+				w.Header().Add(k, v)
+			}
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(h.statusCode())
+		if s.Format == JSONArray {
+			w.Write([]byte("["))
+		}
+		flush(w)
+		started = true
+	}
+	yield := func(item interface{}) error {
+		if aborted {
+			return errStreamAborted
+		}
+		b, err := encodeJSON(item)
+		if err != nil {
+			return err
+		}
+		if s.Delay != nil && !sleep(r.Context(), h.Clock, s.Delay.Duration()) {
+			aborted = true
+			return errStreamAborted
+		}
+		if !started {
+			start()
+		}
+		if s.Format == JSONArray {
+			b = b[:len(b)-1]
+			if n > 0 {
+				b = append([]byte(","), b...)
+			}
+		}
+		if s.Fault != NoFault && n == s.FailAfter {
+			aborted = true
+			switch s.Fault {
+			case FaultTruncatedBody:
+				w.Write(b[:len(b)/2])
+				flush(w)
+				abort(w, false)
+			case FaultMalformedJSON:
+				w.Write(b[:len(b)/2])
+				flush(w)
+			default:
+				abort(w, true)
+			}
+			return errStreamAborted
+		}
+		if _, err := w.Write(b); err != nil {
+			aborted = true
+			return errStreamAborted
+		}
+		flush(w)
+		n++
+		return nil
+	}
+
+	err := s.Items(h.RequestBody, params, r.URL.Query(), yield)
+	if aborted {
+		return
+	}
+	if err != nil {
+		if started {
+			abort(w, false)
+			return
+		}
+		code := http.StatusBadRequest
+		var se *StatusError
+		if errors.As(err, &se) {
+			code = se.StatusCode
+		}
+		h.errorResponse(w, err, code)
+		return
+	}
+	if !started {
+		start()
+	}
+	if s.Fault != NoFault && n == s.FailAfter {
+		abort(w, true)
+		return
+	}
+	if s.Format == JSONArray {
+		w.Write([]byte("]"))
+	}
+}
@@ -0,0 +1,191 @@
+package fakehttp
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestJSONHandler_ServeHTTP_stream(t *testing.T) {
+	cases := []struct {
+		name            string
+		stream          Stream
+		wantCode        int
+		wantContentType string
+		wantBody        string
+	}{
+		{name: "ndjson", stream: Stream{Format: NDJSON, Items: StreamSlice(map[string]int{"a": 1}, map[string]int{"a": 2})}, wantCode: 200, wantContentType: "application/x-ndjson", wantBody: `{"a":1}` + "\n" + `{"a":2}` + "\n"},
+		{name: "json_array", stream: Stream{Format: JSONArray, Items: StreamSlice(map[string]int{"a": 1}, map[string]int{"a": 2})}, wantCode: 200, wantContentType: "application/json", wantBody: `[{"a":1},{"a":2}]`},
+		{name: "empty_array", stream: Stream{Format: JSONArray, Items: StreamSlice()}, wantCode: 200, wantContentType: "application/json", wantBody: `[]`},
+		{name: "delay", stream: Stream{Format: NDJSON, Items: StreamSlice(1, 2), Delay: FixedDelay(time.Millisecond)}, wantCode: 200, wantContentType: "application/x-ndjson", wantBody: "1\n2\n"},
+		{name: "error", stream: Stream{Items: func(_ interface{}, _ []string, _ url.Values, _ func(interface{}) error) error {
+			return errors.New("bad request")
+		}}, wantCode: 400},
+		{name: "status_error", stream: Stream{Items: func(_ interface{}, _ []string, _ url.Values, _ func(interface{}) error) error {
+			return &StatusError{StatusCode: 503, Err: errors.New("unavailable")}
+		}}, wantCode: 503},
+		{name: "no_items", stream: Stream{}, wantCode: 500},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			stream := tt.stream
+			h := JSONHandler{
+				Method:       "GET",
+				PathFmt:      "/logs",
+				ResponseCode: 200,
+				Stream:       &stream,
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/logs", nil))
+
+			if w.Code != tt.wantCode {
+				t.Fatalf("want %v, but got %v: %v", tt.wantCode, w.Code, w.Body.String())
+			}
+			if tt.wantContentType == "" {
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.wantContentType {
+				t.Fatalf("want Content-Type %v, but got %v", tt.wantContentType, got)
+			}
+			if !w.Flushed {
+				t.Fatalf("want flushed, but not")
+			}
+			if got := w.Body.String(); got != tt.wantBody {
+				t.Fatalf("want %q, but got %q", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestJSONHandler_ServeHTTP_streamScenario(t *testing.T) {
+	scenario := NewScenario("logs")
+	h := JSONHandler{
+		Method:        "GET",
+		PathFmt:       "/logs",
+		ResponseCode:  200,
+		Stream:        &Stream{Items: StreamSlice(1, 2)},
+		Scenario:      scenario,
+		RequiredState: ScenarioStarted,
+		NewState:      "streamed",
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/logs", nil))
+
+	if w.Code != 200 || w.Body.String() != "1\n2\n" {
+		t.Fatalf("unexpected response: %v %q", w.Code, w.Body.String())
+	}
+	if got := scenario.State(); got != "streamed" {
+		t.Fatalf("want state streamed, but got %v", got)
+	}
+}
+
+func TestJSONHandler_ServeHTTP_streamChannel(t *testing.T) {
+	ch := make(chan interface{})
+	h := JSONHandler{
+		Method:       "GET",
+		PathFmt:      "/logs",
+		ResponseCode: 200,
+		Stream:       &Stream{Format: NDJSON, Items: StreamChannel(ch)},
+	}
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	go func() { ch <- "first" }()
+	res, err := http.Get(s.URL + "/logs")
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	defer res.Body.Close()
+	r := bufio.NewReader(res.Body)
+
+	// Each item is received before the stream ends only if it is flushed.
+	for _, want := range []string{"first", "second"} {
+		if want != "first" {
+			ch <- want
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("should not be error, but: %v", err)
+		}
+		if line != `"`+want+`"`+"\n" {
+			t.Fatalf("want %v, but got %q", want, line)
+		}
+	}
+	close(ch)
+	if _, err := r.ReadString('\n'); err == nil {
+		t.Fatalf("want the end of the stream, but not")
+	}
+}
+
+func TestJSONHandler_ServeHTTP_streamFault(t *testing.T) {
+	cases := []struct {
+		fault    Fault
+		fail     bool
+		wantBody string
+	}{
+		{fault: FaultConnectionReset, fail: true, wantBody: "[1"},
+		{fault: FaultTruncatedBody, fail: true, wantBody: "[1,"},
+		{fault: FaultMalformedJSON, wantBody: "[1,"},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.fault.String(), func(t *testing.T) {
+			h := JSONHandler{
+				Method:       "GET",
+				PathFmt:      "/items",
+				ResponseCode: 200,
+				Stream: &Stream{
+					Format:    JSONArray,
+					Items:     StreamSlice(1, 22, 3),
+					Fault:     tt.fault,
+					FailAfter: 1,
+				},
+			}
+			s := httptest.NewServer(h)
+			defer s.Close()
+
+			b, err := get(s.URL + "/items")
+			if tt.fail && err == nil {
+				t.Fatalf("should be error, but not: %q", b)
+			}
+			if !tt.fail && err != nil {
+				t.Fatalf("should not be error, but: %v", err)
+			}
+			if got := string(b); got != tt.wantBody {
+				t.Fatalf("want %q, but got %q", tt.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestMultipleHandler_ServeHTTP_streamJournal(t *testing.T) {
+	items := make([]interface{}, 100)
+	for i := range items {
+		items[i] = i % 10
+	}
+	h := NewMultipleHandler([]JSONHandler{{
+		Method:       "GET",
+		PathFmt:      "/logs",
+		ResponseCode: 200,
+		Stream:       &Stream{Items: StreamSlice(items...)},
+	}})
+	h.Journal = &Journal{MaxResponseBodySize: 10}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/logs", nil))
+
+	if w.Body.Len() != 200 {
+		t.Fatalf("want the whole stream, but got %v bytes", w.Body.Len())
+	}
+	entries := h.Journal.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("want 1 entry, but got %v", len(entries))
+	}
+	if e := entries[0]; e.ResponseBody != "0\n1\n2\n3\n4\n" || !e.ResponseBodyTruncated {
+		t.Fatalf("want the response body truncated, but got %q, %v", e.ResponseBody, e.ResponseBodyTruncated)
+	}
+}
@@ -0,0 +1,302 @@
+package fakehttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Stub is a declarative definition of a JSONHandler.
+// It can be written in a JSON or YAML file and loaded with LoadStubs().
+// For example:
+//
+//	request:
+//	  method: GET
+//	  path: /users/*
+//	  query:
+//	    verbose: "true"
+//	response:
+//	  status: 200
+//	  headers:
+//	    X-Request-Id: abc
+//	  body:
+//	    id: 1
+//	    name: test-user
+type Stub struct {
+	Request  StubRequest  `json:"request"`
+	Response StubResponse `json:"response"`
+	// Scenario is the name of the scenario that the stub depends on.
+	Scenario string `json:"scenario,omitempty"`
+	// RequiredState is used as JSONHandler.RequiredState.
+	RequiredState string `json:"requiredState,omitempty"`
+	// NewState is used as JSONHandler.NewState.
+	NewState string `json:"newState,omitempty"`
+	// Responses are returned in order instead of Response if not empty.
+	Responses []StubResponse `json:"responses,omitempty"`
+	// Exhausted specifies what to do when all the Responses are consumed:
+	// "repeatLast" (default), "cycle" or "fail".
+	Exhausted string `json:"exhausted,omitempty"`
+	// Delay is a fixed time to first byte such as "100ms".
+	Delay string `json:"delay,omitempty"`
+	// TotalDelay is a fixed total duration of the response such as "1s".
+	TotalDelay string `json:"totalDelay,omitempty"`
+	// Fault is the name of a Fault to inject such as "connectionReset".
+	Fault string `json:"fault,omitempty"`
+	// FaultRate is the probability in (0, 1] to inject Fault.  Defaults to 1.
+	FaultRate float64 `json:"faultRate,omitempty"`
+}
+
+// StubRequest describes the HTTP request that a Stub matches.
+type StubRequest struct {
+	// Method is an HTTP request method.  It must not be an empty string.
+	Method string `json:"method"`
+	// Path is a pattern of URL paths, used as JSONHandler.PathFmt.  It must
+	// not be an empty string.
+	Path string `json:"path"`
+	// Query is URL query parameters that the request must have.
+	Query map[string]string `json:"query,omitempty"`
+	// Headers is HTTP headers that the request must have.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is a JSON value that the request body must be equivalent to.
+	Body interface{} `json:"body,omitempty"`
+}
+
+// StubResponse describes the HTTP response returned by a Stub.
+type StubResponse struct {
+	// Status is an HTTP response code.  Defaults to 200.
+	Status int `json:"status,omitempty"`
+	// Headers is HTTP headers added to the response.
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is a JSON value of the response body.
+	Body interface{} `json:"body,omitempty"`
+	// BodyFile is a path to a file containing the JSON response body.
+	// A relative path is resolved from the directory of the stub file.
+	// Ignored if Body is specified.
+	BodyFile string `json:"bodyFile,omitempty"`
+}
+
+// JSONHandler converts s into a JSONHandler.
+// baseDir is used to resolve a relative path in s.Response.BodyFile, and
+// scenarios is used to look up s.Scenario.  scenarios may be nil if the stub
+// does not depend on a scenario.
+func (s Stub) JSONHandler(baseDir string, scenarios *Scenarios) (JSONHandler, error) {
+	if s.Request.Method == "" || s.Request.Path == "" {
+		return JSONHandler{}, errors.New("stub: method and path must not be empty")
+	}
+	var scenario *Scenario
+	if s.Scenario != "" {
+		if scenarios == nil {
+			return JSONHandler{}, errors.New("stub: scenarios are not available")
+		}
+		scenario = scenarios.Get(s.Scenario)
+	}
+	if _, err := path.Match(s.Request.Path, ""); err != nil {
+		return JSONHandler{}, fmt.Errorf("stub: invalid path %v: %w", s.Request.Path, err)
+	}
+
+	matchers := []Matcher{}
+	for k, v := range s.Request.Query {
+		matchers = append(matchers, QueryMatcher(k, v))
+	}
+	for k, v := range s.Request.Headers {
+		matchers = append(matchers, HeaderMatcher(k, v))
+	}
+	if s.Request.Body != nil {
+		matchers = append(matchers, BodyJSONMatcher(s.Request.Body))
+	}
+
+	h := JSONHandler{
+		Method:        s.Request.Method,
+		PathFmt:       s.Request.Path,
+		ResponseCode:  http.StatusOK,
+		Matchers:      matchers,
+		Scenario:      scenario,
+		RequiredState: s.RequiredState,
+		NewState:      s.NewState,
+	}
+
+	if s.Delay != "" || s.TotalDelay != "" {
+		firstByte, err := parseStubDelay(s.Delay)
+		if err != nil {
+			return JSONHandler{}, err
+		}
+		total, err := parseStubDelay(s.TotalDelay)
+		if err != nil {
+			return JSONHandler{}, err
+		}
+		h.Latency = &Latency{FirstByte: firstByte, Total: total}
+	}
+
+	if s.Fault != "" {
+		fault, err := ParseFault(s.Fault)
+		if err != nil {
+			return JSONHandler{}, fmt.Errorf("stub: %w", err)
+		}
+		rate := s.FaultRate
+		if rate == 0 {
+			rate = 1
+		}
+		h.Fault = NewFaultInjector(fault, rate, stubFaultSeed)
+	}
+
+	if len(s.Responses) != 0 {
+		policy, ok := stubSequencePolicies[s.Exhausted]
+		if !ok {
+			return JSONHandler{}, fmt.Errorf("stub: invalid exhausted policy: %v", s.Exhausted)
+		}
+		responses := make([]Response, 0, len(s.Responses))
+		for _, sr := range s.Responses {
+			res, err := sr.response(baseDir)
+			if err != nil {
+				return JSONHandler{}, err
+			}
+			responses = append(responses, res)
+		}
+		h.Sequence = NewSequence(policy, responses...)
+		return h, nil
+	}
+
+	res, err := s.Response.response(baseDir)
+	if err != nil {
+		return JSONHandler{}, err
+	}
+	return h.withResponse(res), nil
+}
+
+func parseStubDelay(s string) (Delay, error) {
+	if s == "" {
+		return nil, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("stub: %w", err)
+	}
+	return FixedDelay(d), nil
+}
+
+// stubFaultSeed is the seed of the random numbers deciding whether to inject
+// a fault, so that stub files behave reproducibly.
+const stubFaultSeed = 1
+
+var stubSequencePolicies = map[string]SequencePolicy{
+	"":           RepeatLast,
+	"repeatLast": RepeatLast,
+	"cycle":      Cycle,
+	"fail":       FailWhenExhausted,
+}
+
+func (r StubResponse) response(baseDir string) (Response, error) {
+	header := http.Header{}
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+
+	body := r.Body
+	if body == nil && r.BodyFile != "" {
+		p := r.BodyFile
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(baseDir, p)
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return Response{}, fmt.Errorf("stub: %w", err)
+		}
+		if !json.Valid(b) {
+			return Response{}, fmt.Errorf("stub: %v is not valid JSON", p)
+		}
+		body = json.RawMessage(b)
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return Response{StatusCode: status, Header: header, Body: body}, nil
+}
+
+// LoadStubFile reads stubs from a JSON or YAML file.
+// The file contains either a single stub or an array of stubs.
+func LoadStubFile(filename string) ([]Stub, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+	case ".yaml", ".yml":
+		var v interface{}
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return nil, fmt.Errorf("%v: %w", filename, err)
+		}
+		if b, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("%v: %w", filename, err)
+		}
+	default:
+		return nil, fmt.Errorf("%v: unsupported file extension", filename)
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		stubs := []Stub{}
+		if err := json.Unmarshal(b, &stubs); err != nil {
+			return nil, fmt.Errorf("%v: %w", filename, err)
+		}
+		return stubs, nil
+	}
+
+	stub := Stub{}
+	if err := json.Unmarshal(b, &stub); err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	return []Stub{stub}, nil
+}
+
+// LoadStubs creates a MultipleHandler from the stub files in the directory.
+// Files with the extensions .json, .yaml and .yml are loaded in lexical order,
+// and subdirectories are not searched, so they can be used to store the files
+// referenced by StubResponse.BodyFile.
+func LoadStubs(dir string) (*MultipleHandler, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	handlers := []JSONHandler{}
+	scenarios := &Scenarios{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(f.Name())) {
+		case ".json", ".yaml", ".yml":
+		default:
+			continue
+		}
+
+		filename := filepath.Join(dir, f.Name())
+		stubs, err := LoadStubFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range stubs {
+			h, err := s.JSONHandler(dir, scenarios)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", filename, err)
+			}
+			handlers = append(handlers, h)
+		}
+	}
+
+	h := NewMultipleHandler(handlers)
+	h.Scenarios = scenarios
+	return h, nil
+}
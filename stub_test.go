@@ -0,0 +1,159 @@
+package fakehttp
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	p := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadStubs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fakehttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile(t, dir, "01_users.yaml", `
+- request:
+    method: GET
+    path: /users/*
+    query:
+      verbose: "true"
+  response:
+    status: 200
+    headers:
+      X-Test: verbose
+    body:
+      name: verbose-user
+- request:
+    method: GET
+    path: /users/*
+  response:
+    bodyFile: files/user.json
+`)
+	writeFile(t, dir, "02_create.json", `{
+  "request": {
+    "method": "POST",
+    "path": "/users",
+    "body": {"name": "new-user"}
+  },
+  "response": {"status": 201, "body": {"id": 2}}
+}`)
+	writeFile(t, dir, "files/user.json", `{"name": "file-user"}`)
+	writeFile(t, dir, "README.md", "ignored")
+
+	h, err := LoadStubs(dir)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+
+	cases := []struct {
+		method     string
+		target     string
+		body       string
+		wantCode   int
+		wantBody   string
+		wantHeader string
+	}{
+		{method: "GET", target: "/users/1?verbose=true", wantCode: 200, wantBody: `{"name":"verbose-user"}`, wantHeader: "verbose"},
+		{method: "GET", target: "/users/1", wantCode: 200, wantBody: `{"name":"file-user"}`},
+		{method: "POST", target: "/users", body: `{ "name" : "new-user" }`, wantCode: 201, wantBody: `{"id":2}`},
+		{method: "POST", target: "/users", body: `{"name": "other-user"}`, wantCode: 404},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.method+tt.target, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://localhost"+tt.target, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			res := w.Result()
+			if res.StatusCode != tt.wantCode {
+				t.Fatalf("want %v, but got %v", tt.wantCode, res.StatusCode)
+			}
+			if tt.wantBody == "" {
+				return
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			if got := strings.TrimSpace(string(b)); got != tt.wantBody {
+				t.Fatalf("want %v, but got %v", tt.wantBody, got)
+			}
+			if got := res.Header.Get("X-Test"); got != tt.wantHeader {
+				t.Fatalf("want %v, but got %v", tt.wantHeader, got)
+			}
+		})
+	}
+}
+
+func TestLoadStubs_invalidStub(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+	}{
+		{name: "no_method.json", content: `{"request": {"path": "/users"}}`},
+		{name: "no_path.yaml", content: "request:\n  method: GET\n"},
+		{name: "bad_pattern.json", content: `{"request": {"method": "GET", "path": "/users/["}}`},
+		{name: "no_body_file.json", content: `{"request": {"method": "GET", "path": "/users"}, "response": {"bodyFile": "none.json"}}`},
+		{name: "broken.json", content: `{`},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "fakehttp")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			writeFile(t, dir, tt.name, tt.content)
+			if _, err := LoadStubs(dir); err == nil {
+				t.Fatalf("should be error, but not")
+			}
+		})
+	}
+}
+
+func TestBodyJSONMatcher(t *testing.T) {
+	cases := []struct {
+		input string
+		want  bool
+	}{
+		{input: `{"a": 1, "b": [true, null]}`, want: true},
+		{input: `{"b":[true,null],"a":1}`, want: true},
+		{input: `{"a": 2, "b": [true, null]}`, want: false},
+		{input: `not json`, want: false},
+		{input: ``, want: false},
+	}
+
+	var value interface{}
+	json.Unmarshal([]byte(`{"a": 1, "b": [true, null]}`), &value)
+	m := BodyJSONMatcher(value)
+
+	for _, tt := range cases {
+		t.Run(tt.input, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "http://localhost/", strings.NewReader(tt.input))
+			if got := m(req); got != tt.want {
+				t.Fatalf("want %v, but got %v", tt.want, got)
+			}
+			b, _ := ioutil.ReadAll(req.Body)
+			if string(b) != tt.input {
+				t.Fatalf("body should be restored, but got %v", string(b))
+			}
+		})
+	}
+}
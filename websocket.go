@@ -0,0 +1,404 @@
+package fakehttp
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// WebSocketMessage is a message of a WebSocket conversation.
+type WebSocketMessage struct {
+	// FromClient reports whether the message was sent by the client.
+	FromClient bool
+	// Binary reports whether the message is a binary message rather than a
+	// text message.
+	Binary bool
+	// Data is the content of the message.
+	Data string
+}
+
+// WebSocketAction is a step of the conversation scripted by WebSocketHandler.
+type WebSocketAction struct {
+	// Delay is how long to wait before the action.
+	Delay time.Duration
+	// Send is sent to the client if it is not nil.  A string is sent as a
+	// text message and []byte as a binary message.  Other values are sent as
+	// JSON text messages.
+	Send interface{}
+	// Expect is the JSON that the next message from the client must be
+	// equivalent to, if it is not nil.  Object key order and whitespace are
+	// ignored.  It is checked after Send is sent.
+	Expect interface{}
+}
+
+// WebSocketHandler is a mock of a WebSocket endpoint.  The HTTP request is
+// matched in the same way as JSONHandler, and upgraded to WebSocket by
+// hijacking the connection.  The conversation is recorded in
+// JournalEntry.WebSocket when served by MultipleHandler.
+type WebSocketHandler struct {
+	// PathFmt is a pattern of URL paths to bind a handler to.  See
+	// JSONHandler.PathFmt.
+	PathFmt string
+	// PathRegexp is a regular expression of URL paths to bind a handler to.
+	// See JSONHandler.PathRegexp.
+	PathRegexp *regexp.Regexp
+	// Method is an HTTP request method, which is "GET" for WebSocket.  Skip
+	// the HTTP method check if it is an empty string.
+	Method string
+	// Matchers are additional conditions that the HTTP request must satisfy.
+	Matchers []Matcher
+	// ResponseHeader is added to the header of the handshake response.
+	ResponseHeader http.Header
+	// Script is the conversation performed in order after the handshake.
+	// Then the connection is closed normally unless KeepOpen is true.
+	Script []WebSocketAction
+	// KeepOpen keeps the connection open after Script, receiving messages
+	// until the client closes the connection.
+	KeepOpen bool
+	// Errorf reports a message from the client that does not match
+	// WebSocketAction.Expect, e.g. testing.T.Errorf.  In any case, the
+	// connection is closed with the status 1008 (policy violation).
+	Errorf func(format string, args ...interface{})
+	// Clock is used to wait for WebSocketAction.Delay.  If nil, the system
+	// clock is used, or MultipleHandler.Clock when served by MultipleHandler.
+	Clock Clock
+	// ErrResponseFn specifies how to return an error response to an invalid
+	// handshake.  See JSONHandler.ErrResponseFn.
+	ErrResponseFn func(http.ResponseWriter, error, int)
+}
+
+// The opcodes of WebSocket frames.
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// The status codes of WebSocket close frames.
+const (
+	wsNormalClosure   = 1000
+	wsPolicyViolation = 1008
+)
+
+// webSocketGUID is concatenated with Sec-WebSocket-Key to compute
+// Sec-WebSocket-Accept.
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// maxWebSocketMessage is the maximum size of a message from the client.
+const maxWebSocketMessage = 32 << 20
+
+// errWebSocketClosed is returned when the client closed the connection.
+var errWebSocketClosed = errors.New("websocket: closed by the client")
+
+// jsonHandler returns the JSONHandler that matches the same HTTP requests as
+// h, and serves them with h.
+func (h WebSocketHandler) jsonHandler() JSONHandler {
+	jh := JSONHandler{
+		PathFmt:       h.PathFmt,
+		PathRegexp:    h.PathRegexp,
+		Method:        h.Method,
+		Matchers:      h.Matchers,
+		ErrResponseFn: h.ErrResponseFn,
+	}
+	jh.serve = func(w http.ResponseWriter, r *http.Request, clock Clock) {
+		if h.Clock == nil {
+			h.Clock = clock
+		}
+		h.converse(w, r, jh.errorResponse)
+	}
+	return jh
+}
+
+// ServeHTTP is a method to implement http.Handler.
+func (h WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.jsonHandler().ServeHTTP(w, r)
+}
+
+// converse upgrades the connection and performs Script.
+func (h WebSocketHandler) converse(w http.ResponseWriter, r *http.Request, errorResponse func(http.ResponseWriter, error, int)) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		errorResponse(w, errors.New("websocket: not a WebSocket handshake"), http.StatusBadRequest)
+		return
+	}
+	if v := r.Header.Get("Sec-WebSocket-Version"); v != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		errorResponse(w, fmt.Errorf("websocket: unsupported version: %v", v), http.StatusUpgradeRequired)
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		errorResponse(w, errors.New("websocket: missing Sec-WebSocket-Key"), http.StatusBadRequest)
+		return
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		errorResponse(w, errors.New("websocket: hijack is not supported"), http.StatusInternalServerError)
+		return
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		errorResponse(w, err, http.StatusInternalServerError)
+		return
+	}
+	defer conn.Close()
+
+	c := &wsConn{conn: conn, rw: rw}
+	if rec, ok := w.(webSocketRecorder); ok {
+		defer func() {
+			rec.recordWebSocket(c.messages)
+		}()
+	}
+
+	sum := sha1.Sum([]byte(key + webSocketGUID))
+	// The header is set to w as well to be recorded in the journal.
+	header := w.Header()
+	for k, vs := range h.ResponseHeader {
+		for _, v := range vs {
+			header.Add(k, v)
+		}
+	}
+	header.Set("Upgrade", "websocket")
+	header.Set("Connection", "Upgrade")
+	header.Set("Sec-WebSocket-Accept", base64.StdEncoding.EncodeToString(sum[:]))
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	header.Write(rw)
+	rw.WriteString("\r\n")
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	for i, a := range h.Script {
+		if !sleep(r.Context(), h.Clock, a.Delay) {
+			return
+		}
+		if a.Send != nil {
+			if err := c.send(a.Send); err != nil {
+				return
+			}
+		}
+		if a.Expect == nil {
+			continue
+		}
+		m, err := c.receive()
+		if err != nil {
+			if err != errWebSocketClosed && h.Errorf != nil {
+				h.Errorf("fakehttp: websocket %v: want message %v, but got %v", r.URL.Path, i, err)
+			}
+			return
+		}
+		want, err := normalizeJSON(a.Expect)
+		if err != nil || m.Binary || !equalJSON(want, []byte(m.Data)) {
+			b, _ := json.Marshal(a.Expect)
+			if h.Errorf != nil {
+				h.Errorf("fakehttp: websocket %v: message %v: want %s, but got %v", r.URL.Path, i, b, m.Data)
+			}
+			c.close(wsPolicyViolation, "unexpected message")
+			return
+		}
+	}
+
+	if h.KeepOpen {
+		for {
+			if _, err := c.receive(); err != nil {
+				return
+			}
+		}
+	}
+	c.close(wsNormalClosure, "")
+}
+
+// AddWebSocketHandler adds a WebSocketHandler to mock.
+// The WebSocketHandler argument specifies that the Method and PathFmt fields
+// must not be empty strings.  PathFmt may be empty if PathRegexp is
+// specified.
+func (h *MultipleHandler) AddWebSocketHandler(handler WebSocketHandler) {
+	h.AddHandler(handler.jsonHandler())
+}
+
+// webSocketRecorder is implemented by the http.ResponseWriter that records the
+// WebSocket conversation in the journal.
+type webSocketRecorder interface {
+	recordWebSocket([]WebSocketMessage)
+}
+
+// headerContains reports whether the comma-separated header contains the
+// token, ignoring case.
+func headerContains(header http.Header, key, token string) bool {
+	for _, v := range header.Values(key) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wsConn is the server side of a WebSocket connection.
+type wsConn struct {
+	conn     net.Conn
+	rw       *bufio.ReadWriter
+	messages []WebSocketMessage
+}
+
+// send sends v as a message.  See WebSocketAction.Send.
+func (c *wsConn) send(v interface{}) error {
+	m := WebSocketMessage{}
+	switch v := v.(type) {
+	case string:
+		m.Data = v
+	case []byte:
+		m.Binary, m.Data = true, string(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		m.Data = string(b)
+	}
+	opcode := byte(wsText)
+	if m.Binary {
+		opcode = wsBinary
+	}
+	c.messages = append(c.messages, m)
+	return c.writeFrame(opcode, []byte(m.Data))
+}
+
+// receive returns the next message from the client, answering the control
+// frames meanwhile.  It returns errWebSocketClosed if the client closed the
+// connection.
+func (c *wsConn) receive() (WebSocketMessage, error) {
+	var m WebSocketMessage
+	var data []byte
+	started := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return m, err
+		}
+		switch opcode {
+		case wsPing:
+			if err := c.writeFrame(wsPong, payload); err != nil {
+				return m, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			// Echo the status code to complete the closing handshake.
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			c.writeFrame(wsClose, payload)
+			return m, errWebSocketClosed
+		case wsText, wsBinary:
+			if started {
+				return m, errors.New("websocket: unexpected data frame in a fragmented message")
+			}
+			started, m.Binary = true, opcode == wsBinary
+		case wsContinuation:
+			if !started {
+				return m, errors.New("websocket: unexpected continuation frame")
+			}
+		default:
+			return m, fmt.Errorf("websocket: unknown opcode: %v", opcode)
+		}
+		data = append(data, payload...)
+		if len(data) > maxWebSocketMessage {
+			return m, errors.New("websocket: message too large")
+		}
+		if fin {
+			m.FromClient, m.Data = true, string(data)
+			c.messages = append(c.messages, m)
+			return m, nil
+		}
+	}
+}
+
+// close sends a close frame and waits for the client to close the connection.
+func (c *wsConn) close(code uint16, reason string) {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, code)
+	payload = append(payload, reason...)
+	if err := c.writeFrame(wsClose, payload); err != nil {
+		return
+	}
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		if _, err := c.receive(); err != nil {
+			return
+		}
+	}
+}
+
+// readFrame reads a frame, which must be masked, from the client.
+func (c *wsConn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode = head[0]&0x80 != 0, head[0]&0x0f
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("websocket: unmasked frame from the client")
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.rw, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.rw, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+	if n > maxWebSocketMessage {
+		return false, 0, nil, errors.New("websocket: frame too large")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload = make([]byte, n)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes an unfragmented, unmasked frame to the client.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	head := []byte{0x80 | opcode}
+	switch n := len(payload); {
+	case n < 126:
+		head = append(head, byte(n))
+	case n <= 0xffff:
+		head = append(head, 126, byte(n>>8), byte(n))
+	default:
+		head = append(head, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(head[2:], uint64(n))
+	}
+	c.rw.Write(head)
+	c.rw.Write(payload)
+	return c.rw.Flush()
+}
@@ -0,0 +1,221 @@
+package fakehttp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testWebSocket is a minimal WebSocket client for tests.
+type testWebSocket struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+	res  *http.Response
+}
+
+func dialWebSocket(t *testing.T, url string) *testWebSocket {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	req, _ := http.NewRequest("GET", url+"/notifications", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return &testWebSocket{t: t, conn: conn, r: r, res: res}
+}
+
+// write writes a masked frame.
+func (c *testWebSocket) write(fin bool, opcode byte, payload string) {
+	head := []byte{opcode, 0x80 | byte(len(payload))}
+	if fin {
+		head[0] |= 0x80
+	}
+	mask := []byte{1, 2, 3, 4}
+	b := []byte(payload)
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+	if _, err := c.conn.Write(append(append(head, mask...), b...)); err != nil {
+		c.t.Fatalf("should not be error, but: %v", err)
+	}
+}
+
+func (c *testWebSocket) read() (byte, string) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		c.t.Fatalf("should not be error, but: %v", err)
+	}
+	n := int(head[1] & 0x7f)
+	if n == 126 {
+		var b [2]byte
+		io.ReadFull(c.r, b[:])
+		n = int(binary.BigEndian.Uint16(b[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		c.t.Fatalf("should not be error, but: %v", err)
+	}
+	return head[0] & 0x0f, string(payload)
+}
+
+func TestWebSocketHandler_ServeHTTP(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	h := NewMultipleHandler(nil)
+	h.Journal = &Journal{}
+	h.AddWebSocketHandler(WebSocketHandler{
+		Method:  "GET",
+		PathFmt: "/notifications",
+		Script: []WebSocketAction{
+			{Send: map[string]string{"type": "hello"}},
+			{Expect: map[string]interface{}{"type": "subscribe", "topics": []string{"a"}}},
+			{Send: long},
+			{Send: []byte{0, 1}},
+		},
+		Errorf: t.Errorf,
+	})
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	c := dialWebSocket(t, s.URL)
+	if c.res.StatusCode != 101 {
+		t.Fatalf("want 101, but got %v", c.res.StatusCode)
+	}
+	if got := c.res.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("unexpected Sec-WebSocket-Accept: %v", got)
+	}
+
+	if op, got := c.read(); op != wsText || got != `{"type":"hello"}` {
+		t.Fatalf("unexpected message: %v %v", op, got)
+	}
+	c.write(true, wsPing, "p")
+	if op, got := c.read(); op != wsPong || got != "p" {
+		t.Fatalf("want pong, but got %v %v", op, got)
+	}
+	// A fragmented message with the keys in a different order.
+	c.write(false, wsText, `{"topics": ["a"],`)
+	c.write(true, wsContinuation, ` "type": "subscribe"}`)
+	if op, got := c.read(); op != wsText || got != long {
+		t.Fatalf("unexpected message: %v %v", op, got)
+	}
+	if op, got := c.read(); op != wsBinary || got != "\x00\x01" {
+		t.Fatalf("unexpected message: %v %q", op, got)
+	}
+	if op, got := c.read(); op != wsClose || got != "\x03\xe8" {
+		t.Fatalf("want close 1000, but got %v %q", op, got)
+	}
+	c.write(true, wsClose, "\x03\xe8")
+
+	var entries []JournalEntry
+	for i := 0; i < 100 && len(entries) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		entries = h.Journal.Entries()
+	}
+	if len(entries) != 1 || entries[0].StatusCode != 101 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	want := []WebSocketMessage{
+		{Data: `{"type":"hello"}`},
+		{FromClient: true, Data: `{"topics": ["a"], "type": "subscribe"}`},
+		{Data: long},
+		{Binary: true, Data: "\x00\x01"},
+	}
+	if got := entries[0].WebSocket; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %+v, but got %+v", want, got)
+	}
+}
+
+func TestWebSocketHandler_ServeHTTP_unexpectedMessage(t *testing.T) {
+	reported := make(chan string, 1)
+	h := WebSocketHandler{
+		Method:  "GET",
+		PathFmt: "/notifications",
+		Script:  []WebSocketAction{{Expect: map[string]string{"type": "subscribe"}}},
+		Errorf: func(format string, args ...interface{}) {
+			reported <- format
+		},
+	}
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	c := dialWebSocket(t, s.URL)
+	c.write(true, wsText, `{"type":"unsubscribe"}`)
+	if op, got := c.read(); op != wsClose || !strings.HasPrefix(got, "\x03\xf0") {
+		t.Fatalf("want close 1008, but got %v %q", op, got)
+	}
+	c.write(true, wsClose, "")
+	select {
+	case <-reported:
+	case <-time.After(time.Second):
+		t.Fatalf("want the unexpected message to be reported, but not")
+	}
+}
+
+func TestWebSocketHandler_ServeHTTP_keepOpen(t *testing.T) {
+	h := NewMultipleHandler(nil)
+	h.Journal = &Journal{}
+	h.AddWebSocketHandler(WebSocketHandler{
+		Method:   "GET",
+		PathFmt:  "/notifications",
+		KeepOpen: true,
+	})
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	c := dialWebSocket(t, s.URL)
+	c.write(true, wsText, "a")
+	c.write(true, wsText, "b")
+	c.write(true, wsClose, "\x03\xe8")
+	if op, got := c.read(); op != wsClose || got != "\x03\xe8" {
+		t.Fatalf("want close 1000, but got %v %q", op, got)
+	}
+
+	var entries []JournalEntry
+	for i := 0; i < 100 && len(entries) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		entries = h.Journal.Entries()
+	}
+	want := []WebSocketMessage{{FromClient: true, Data: "a"}, {FromClient: true, Data: "b"}}
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0].WebSocket, want) {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestWebSocketHandler_ServeHTTP_badHandshake(t *testing.T) {
+	h := WebSocketHandler{Method: "GET", PathFmt: "/notifications"}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/notifications", nil))
+	if w.Code != 400 {
+		t.Fatalf("want 400, but got %v", w.Code)
+	}
+
+	r := httptest.NewRequest("GET", "http://localhost/notifications", nil)
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "8")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 426 || w.Header().Get("Sec-WebSocket-Version") != "13" {
+		t.Fatalf("want 426 with Sec-WebSocket-Version, but got %v %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,587 @@
+package fakehttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// wireMockAnyMethods are the HTTP methods registered for the WireMock method
+// "ANY".
+var wireMockAnyMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// wireMockDefaultPriority is the priority of a WireMock mapping that does not
+// specify it.
+const wireMockDefaultPriority = 5
+
+type wireMockMapping struct {
+	Priority              int             `json:"priority"`
+	Request               json.RawMessage `json:"request"`
+	Response              json.RawMessage `json:"response"`
+	ScenarioName          string          `json:"scenarioName"`
+	RequiredScenarioState string          `json:"requiredScenarioState"`
+	NewScenarioState      string          `json:"newScenarioState"`
+
+	unknown []string
+}
+
+type wireMockRequest struct {
+	Method          string                     `json:"method"`
+	URL             string                     `json:"url"`
+	URLPath         string                     `json:"urlPath"`
+	URLPattern      string                     `json:"urlPattern"`
+	URLPathPattern  string                     `json:"urlPathPattern"`
+	QueryParameters map[string]wireMockPattern `json:"queryParameters"`
+	Headers         map[string]wireMockPattern `json:"headers"`
+	BodyPatterns    []wireMockPattern          `json:"bodyPatterns"`
+}
+
+type wireMockResponse struct {
+	Status       int                    `json:"status"`
+	Headers      map[string]interface{} `json:"headers"`
+	Body         *string                `json:"body"`
+	JSONBody     interface{}            `json:"jsonBody"`
+	Base64Body   string                 `json:"base64Body"`
+	BodyFileName string                 `json:"bodyFileName"`
+
+	FixedDelayMilliseconds int                          `json:"fixedDelayMilliseconds"`
+	DelayDistribution      *wireMockDelayDistribution   `json:"delayDistribution"`
+	ChunkedDribbleDelay    *wireMockChunkedDribbleDelay `json:"chunkedDribbleDelay"`
+
+	Fault string `json:"fault"`
+}
+
+type wireMockDelayDistribution struct {
+	Type   string  `json:"type"`
+	Median float64 `json:"median"`
+	Sigma  float64 `json:"sigma"`
+	Lower  float64 `json:"lower"`
+	Upper  float64 `json:"upper"`
+}
+
+type wireMockChunkedDribbleDelay struct {
+	NumberOfChunks int `json:"numberOfChunks"`
+	TotalDuration  int `json:"totalDuration"`
+}
+
+// wireMockDelaySeed is the seed of the random delays, so that the delays are
+// reproducible.
+const wireMockDelaySeed = 1
+
+// wireMockFaults maps the supported WireMock faults to Faults.
+var wireMockFaults = map[string]Fault{
+	"CONNECTION_RESET_BY_PEER": FaultConnectionReset,
+	"MALFORMED_RESPONSE_CHUNK": FaultTruncatedBody,
+}
+
+// wireMockPattern is a WireMock value pattern such as `{"equalTo": "abc"}`.
+type wireMockPattern map[string]json.RawMessage
+
+// LoadWireMock creates a MultipleHandler from a WireMock root directory.
+// The stub mappings are read from the `mappings` directory and the files
+// referenced by `bodyFileName` from the `__files` directory.
+//
+// The following WireMock features are supported:
+//   - request: method (including ANY), url, urlPath, urlPattern,
+//     urlPathPattern, queryParameters, headers and bodyPatterns with one of
+//     the equalTo, contains, matches, doesNotMatch, absent and equalToJson
+//     operators per pattern, and caseInsensitive with equalTo
+//   - response: status, headers, body, jsonBody, base64Body and bodyFileName
+//     whose content is JSON, fixedDelayMilliseconds, delayDistribution
+//     (lognormal and uniform), chunkedDribbleDelay and fault
+//     (CONNECTION_RESET_BY_PEER and MALFORMED_RESPONSE_CHUNK)
+//   - priority
+//   - scenarioName, requiredScenarioState and newScenarioState
+//
+// The returned slice reports the unsupported features found.  A mapping with an
+// unsupported request feature is skipped since it cannot be matched correctly,
+// while unsupported response features are ignored.
+func LoadWireMock(root string) (*MultipleHandler, []string, error) {
+	type entry struct {
+		priority int
+		handlers []JSONHandler
+	}
+
+	entries := []entry{}
+	unsupported := []string{}
+	scenarios := &Scenarios{}
+	mappingsDir := filepath.Join(root, "mappings")
+	err := filepath.Walk(mappingsDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.ToLower(filepath.Ext(p)) != ".json" {
+			return nil
+		}
+
+		mappings, err := readWireMockMappings(p)
+		if err != nil {
+			return err
+		}
+		name, _ := filepath.Rel(root, p)
+		for i, m := range mappings {
+			hs, msgs, err := m.jsonHandlers(filepath.Join(root, "__files"), scenarios)
+			for _, msg := range msgs {
+				unsupported = append(unsupported, fmt.Sprintf("%v[%v]: %v", name, i, msg))
+			}
+			if err != nil {
+				unsupported = append(unsupported, fmt.Sprintf("%v[%v]: skipped: %v", name, i, err))
+				continue
+			}
+			if m.Priority == 0 {
+				m.Priority = wireMockDefaultPriority
+			}
+			entries = append(entries, entry{priority: m.Priority, handlers: hs})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].priority < entries[j].priority
+	})
+	handlers := []JSONHandler{}
+	for _, e := range entries {
+		handlers = append(handlers, e.handlers...)
+	}
+	h := NewMultipleHandler(handlers)
+	h.Scenarios = scenarios
+	return h, unsupported, nil
+}
+
+func readWireMockMappings(filename string) ([]wireMockMapping, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var wrapper struct {
+		Mappings []json.RawMessage `json:"mappings"`
+	}
+	if err := json.Unmarshal(b, &wrapper); err != nil {
+		return nil, fmt.Errorf("%v: %w", filename, err)
+	}
+	raws := wrapper.Mappings
+	if raws == nil {
+		raws = []json.RawMessage{b}
+	}
+
+	mappings := make([]wireMockMapping, 0, len(raws))
+	for _, raw := range raws {
+		m := wireMockMapping{}
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return nil, fmt.Errorf("%v: %w", filename, err)
+		}
+		// Post-serve actions and so on are not supported.
+		m.unknown, err = unknownKeys(raw, "id", "uuid", "name", "priority", "persistent", "metadata", "request", "response", "scenarioName", "requiredScenarioState", "newScenarioState")
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", filename, err)
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+// jsonHandlers converts m into JSONHandlers.  It returns more than one
+// JSONHandler if the method is ANY.  The returned messages report the
+// unsupported response features that are ignored.
+func (m wireMockMapping) jsonHandlers(filesDir string, scenarios *Scenarios) ([]JSONHandler, []string, error) {
+	if len(m.unknown) != 0 {
+		return nil, nil, fmt.Errorf("%v is not supported", m.unknown[0])
+	}
+	unknown, err := unknownKeys(m.Request, "method", "url", "urlPath", "urlPattern", "urlPathPattern", "queryParameters", "headers", "bodyPatterns")
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(unknown) != 0 {
+		return nil, nil, fmt.Errorf("request.%v is not supported", unknown[0])
+	}
+	req := wireMockRequest{}
+	if err := json.Unmarshal(m.Request, &req); err != nil {
+		return nil, nil, err
+	}
+	if req.Method == "" {
+		return nil, nil, errors.New("request.method must be specified")
+	}
+
+	base := JSONHandler{Matchers: []Matcher{}}
+	if m.ScenarioName != "" {
+		base.Scenario = scenarios.Get(m.ScenarioName)
+		base.RequiredState = m.RequiredScenarioState
+		base.NewState = m.NewScenarioState
+	}
+	if err := req.setPath(&base); err != nil {
+		return nil, nil, err
+	}
+	for k, p := range req.QueryParameters {
+		fn, err := p.compile()
+		if err != nil {
+			return nil, nil, fmt.Errorf("request.queryParameters.%v: %w", k, err)
+		}
+		key := k
+		base.Matchers = append(base.Matchers, func(r *http.Request) bool {
+			return fn(r.URL.Query()[key])
+		})
+	}
+	for k, p := range req.Headers {
+		fn, err := p.compile()
+		if err != nil {
+			return nil, nil, fmt.Errorf("request.headers.%v: %w", k, err)
+		}
+		key := k
+		base.Matchers = append(base.Matchers, func(r *http.Request) bool {
+			return fn(r.Header.Values(key))
+		})
+	}
+	for i, p := range req.BodyPatterns {
+		matcher, err := p.bodyMatcher()
+		if err != nil {
+			return nil, nil, fmt.Errorf("request.bodyPatterns[%v]: %w", i, err)
+		}
+		base.Matchers = append(base.Matchers, matcher)
+	}
+
+	msgs, err := m.setResponse(&base, filesDir)
+	if err != nil {
+		return nil, msgs, err
+	}
+
+	methods := []string{req.Method}
+	if req.Method == "ANY" {
+		methods = wireMockAnyMethods
+	}
+	handlers := make([]JSONHandler, 0, len(methods))
+	for _, method := range methods {
+		h := base
+		h.Method = method
+		handlers = append(handlers, h)
+	}
+	return handlers, msgs, nil
+}
+
+func (req wireMockRequest) setPath(h *JSONHandler) error {
+	switch {
+	case req.URL != "":
+		u, err := url.Parse(req.URL)
+		if err != nil {
+			return fmt.Errorf("request.url: %w", err)
+		}
+		h.PathFmt = escapePathFmt(u.Path)
+		for k, vs := range u.Query() {
+			for _, v := range vs {
+				h.Matchers = append(h.Matchers, QueryMatcher(k, v))
+			}
+		}
+	case req.URLPath != "":
+		h.PathFmt = escapePathFmt(req.URLPath)
+	case req.URLPathPattern != "":
+		re, err := regexp.Compile("^(?:" + req.URLPathPattern + ")$")
+		if err != nil {
+			return fmt.Errorf("request.urlPathPattern: %w", err)
+		}
+		h.PathRegexp = re
+	case req.URLPattern != "":
+		re, err := regexp.Compile("^(?:" + req.URLPattern + ")$")
+		if err != nil {
+			return fmt.Errorf("request.urlPattern: %w", err)
+		}
+		h.PathRegexp = regexp.MustCompile(".*")
+		h.Matchers = append(h.Matchers, func(r *http.Request) bool {
+			return re.MatchString(r.URL.RequestURI())
+		})
+	default:
+		h.PathRegexp = regexp.MustCompile(".*")
+	}
+	return nil
+}
+
+func (m wireMockMapping) setResponse(h *JSONHandler, filesDir string) ([]string, error) {
+	unknown, err := unknownKeys(m.Response, "status", "headers", "body", "jsonBody", "base64Body", "bodyFileName", "fixedDelayMilliseconds", "delayDistribution", "chunkedDribbleDelay", "fault")
+	if err != nil {
+		return nil, err
+	}
+	msgs := []string{}
+	for _, k := range unknown {
+		msgs = append(msgs, fmt.Sprintf("response.%v is not supported and ignored", k))
+	}
+	res := wireMockResponse{}
+	if len(m.Response) != 0 {
+		if err := json.Unmarshal(m.Response, &res); err != nil {
+			return msgs, err
+		}
+	}
+
+	h.ResponseCode = res.Status
+	if h.ResponseCode == 0 {
+		h.ResponseCode = http.StatusOK
+	}
+
+	latency, msg := res.latency()
+	if msg != "" {
+		msgs = append(msgs, msg)
+	}
+	h.Latency = latency
+
+	if res.Fault != "" {
+		if fault, ok := wireMockFaults[res.Fault]; ok {
+			h.Fault = NewFaultInjector(fault, 1, 0)
+		} else {
+			msgs = append(msgs, fmt.Sprintf("response.fault %v is not supported and ignored", res.Fault))
+		}
+	}
+
+	h.ResponseHeader = http.Header{}
+	for k, v := range res.Headers {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, e := range v {
+				h.ResponseHeader.Add(k, fmt.Sprint(e))
+			}
+		default:
+			h.ResponseHeader.Add(k, fmt.Sprint(v))
+		}
+	}
+	// Content-Type is always set by JSONHandler.
+	h.ResponseHeader.Del("Content-Type")
+
+	var raw []byte
+	switch {
+	case res.JSONBody != nil:
+		b, err := json.Marshal(res.JSONBody)
+		if err != nil {
+			return msgs, fmt.Errorf("response.jsonBody: %w", err)
+		}
+		raw = b
+	case res.Body != nil:
+		raw = []byte(*res.Body)
+	case res.Base64Body != "":
+		b, err := base64.StdEncoding.DecodeString(res.Base64Body)
+		if err != nil {
+			return msgs, fmt.Errorf("response.base64Body: %w", err)
+		}
+		raw = b
+	case res.BodyFileName != "":
+		b, err := ioutil.ReadFile(filepath.Join(filesDir, res.BodyFileName))
+		if err != nil {
+			return msgs, fmt.Errorf("response.bodyFileName: %w", err)
+		}
+		raw = b
+	}
+
+	var body interface{}
+	if len(bytes.TrimSpace(raw)) != 0 {
+		if !json.Valid(raw) {
+			return msgs, errors.New("response body is not JSON")
+		}
+		body = json.RawMessage(raw)
+	}
+	h.ResponseFn = func(_ interface{}, _ []string, _ url.Values) (interface{}, error) {
+		return body, nil
+	}
+	return msgs, nil
+}
+
+// latency converts the delay settings into a Latency.  It returns a message if
+// the delay distribution is not supported.
+func (res wireMockResponse) latency() (*Latency, string) {
+	fixed := time.Duration(res.FixedDelayMilliseconds) * time.Millisecond
+	msg := ""
+	var dist Delay
+	if d := res.DelayDistribution; d != nil {
+		switch d.Type {
+		case "lognormal":
+			dist = LogNormalDelay(time.Duration(d.Median*float64(time.Millisecond)), d.Sigma, wireMockDelaySeed)
+		case "uniform":
+			dist = UniformDelay(time.Duration(d.Lower*float64(time.Millisecond)), time.Duration(d.Upper*float64(time.Millisecond)), wireMockDelaySeed)
+		default:
+			msg = fmt.Sprintf("response.delayDistribution.type %v is not supported and ignored", d.Type)
+		}
+	}
+	if fixed == 0 && dist == nil && res.ChunkedDribbleDelay == nil {
+		return nil, msg
+	}
+
+	l := &Latency{FirstByte: FixedDelay(fixed)}
+	if dist != nil {
+		l.FirstByte = DelayFunc(func() time.Duration {
+			return fixed + dist.Duration()
+		})
+	}
+	if res.ChunkedDribbleDelay != nil {
+		l.Total = FixedDelay(fixed + time.Duration(res.ChunkedDribbleDelay.TotalDuration)*time.Millisecond)
+	}
+	return l, msg
+}
+
+// compile converts p into a function which reports whether the values of a
+// header or a query parameter match.
+func (p wireMockPattern) compile() (func([]string) bool, error) {
+	caseInsensitive := false
+	if raw, ok := p["caseInsensitive"]; ok {
+		if err := json.Unmarshal(raw, &caseInsensitive); err != nil {
+			return nil, err
+		}
+	}
+
+	ops := []string{}
+	for op := range p {
+		if op != "caseInsensitive" {
+			ops = append(ops, op)
+		}
+	}
+	sort.Strings(ops)
+	switch {
+	case len(ops) == 0:
+		return nil, errors.New("no operator is specified")
+	case len(ops) > 1:
+		return nil, fmt.Errorf("more than one operator is specified: %v", strings.Join(ops, ", "))
+	case caseInsensitive && ops[0] != "equalTo":
+		return nil, fmt.Errorf("caseInsensitive is not supported with %v", ops[0])
+	}
+
+	op, raw := ops[0], p[ops[0]]
+	switch op {
+	case "absent":
+		var absent bool
+		if err := json.Unmarshal(raw, &absent); err != nil {
+			return nil, err
+		}
+		return func(vs []string) bool { return (len(vs) == 0) == absent }, nil
+	case "doesNotMatch":
+		fn, err := p.valueFn(op, raw, caseInsensitive)
+		if err != nil {
+			return nil, err
+		}
+		return func(vs []string) bool {
+			for _, v := range vs {
+				if !fn(v) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	}
+	fn, err := p.valueFn(op, raw, caseInsensitive)
+	if err != nil {
+		return nil, err
+	}
+	return func(vs []string) bool {
+		for _, v := range vs {
+			if fn(v) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func (p wireMockPattern) valueFn(op string, raw json.RawMessage, caseInsensitive bool) (func(string) bool, error) {
+	var want string
+	if err := json.Unmarshal(raw, &want); err != nil {
+		return nil, fmt.Errorf("%v: %w", op, err)
+	}
+
+	switch op {
+	case "equalTo":
+		if caseInsensitive {
+			return func(v string) bool { return strings.EqualFold(v, want) }, nil
+		}
+		return func(v string) bool { return v == want }, nil
+	case "contains":
+		return func(v string) bool { return strings.Contains(v, want) }, nil
+	case "matches", "doesNotMatch":
+		re, err := regexp.Compile("^(?:" + want + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", op, err)
+		}
+		if op == "doesNotMatch" {
+			return func(v string) bool { return !re.MatchString(v) }, nil
+		}
+		return re.MatchString, nil
+	}
+	return nil, fmt.Errorf("%v is not supported", op)
+}
+
+func (p wireMockPattern) bodyMatcher() (Matcher, error) {
+	if raw, ok := p["equalToJson"]; ok {
+		if len(p) != 1 {
+			return nil, errors.New("equalToJson options are not supported")
+		}
+		var value interface{}
+		if err := json.Unmarshal(raw, &value); err != nil {
+			return nil, fmt.Errorf("equalToJson: %w", err)
+		}
+		// The expected JSON can also be written as a string.
+		if s, ok := value.(string); ok {
+			if err := json.Unmarshal([]byte(s), &value); err != nil {
+				return nil, fmt.Errorf("equalToJson: %w", err)
+			}
+		}
+		return BodyJSONMatcher(value), nil
+	}
+
+	fn, err := p.compile()
+	if err != nil {
+		return nil, err
+	}
+	return func(r *http.Request) bool {
+		body, err := peekMatchedBody(r)
+		if err != nil {
+			return false
+		}
+		return fn([]string{string(body)})
+	}, nil
+}
+
+// unknownKeys returns the keys of the JSON object that are not in known.
+func unknownKeys(b []byte, known ...string) ([]string, error) {
+	if len(b) == 0 {
+		return []string{}, nil
+	}
+	m := map[string]json.RawMessage{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	ret := []string{}
+	for k := range m {
+		found := false
+		for _, kn := range known {
+			if k == kn {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ret = append(ret, k)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// escapePathFmt escapes the characters which have a special meaning in
+// path.Match().
+func escapePathFmt(p string) string {
+	return strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`).Replace(p)
+}
@@ -0,0 +1,201 @@
+package fakehttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadWireMock(t *testing.T) {
+	root, err := ioutil.TempDir("", "fakehttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	writeFile(t, root, "mappings/users.json", `{
+  "mappings": [
+    {
+      "request": {
+        "method": "GET",
+        "urlPathPattern": "/users/([0-9]+)",
+        "headers": {"Authorization": {"matches": "Bearer .+"}}
+      },
+      "response": {
+        "status": 200,
+        "headers": {"X-Test": "user", "Content-Type": "application/json"},
+        "jsonBody": {"name": "test-user"}
+      }
+    },
+    {
+      "priority": 1,
+      "request": {"method": "GET", "url": "/users/1?verbose=true"},
+      "response": {"status": 200, "bodyFileName": "verbose.json", "fixedDelayMilliseconds": 1, "transformers": ["x"]}
+    },
+    {
+      "request": {
+        "method": "POST",
+        "urlPath": "/users",
+        "bodyPatterns": [{"equalToJson": "{\"name\": \"new-user\"}"}]
+      },
+      "response": {"status": 201, "body": "{\"id\": 2}"}
+    },
+    {
+      "request": {"method": "ANY", "urlPath": "/any"},
+      "response": {"status": 204}
+    }
+  ]
+}`)
+	writeFile(t, root, "mappings/unsupported.json", `{
+  "request": {
+    "method": "GET",
+    "urlPath": "/xml",
+    "bodyPatterns": [{"equalToXml": "<a/>"}]
+  },
+  "response": {"status": 200}
+}`)
+	writeFile(t, root, "mappings/operators.json", `{
+  "mappings": [
+    {
+      "request": {
+        "method": "GET",
+        "urlPath": "/operators",
+        "headers": {"X-Test": {"equalTo": "a", "contains": "b"}}
+      },
+      "response": {"status": 200}
+    },
+    {
+      "request": {
+        "method": "GET",
+        "urlPath": "/case",
+        "queryParameters": {"q": {"contains": "a", "caseInsensitive": true}}
+      },
+      "response": {"status": 200}
+    }
+  ]
+}`)
+	writeFile(t, root, "mappings/scenario.json", `{
+  "mappings": [
+    {
+      "scenarioName": "s",
+      "requiredScenarioState": "Started",
+      "newScenarioState": "done",
+      "request": {"method": "GET", "urlPath": "/scenario"},
+      "response": {"status": 202}
+    },
+    {
+      "scenarioName": "s",
+      "requiredScenarioState": "done",
+      "request": {"method": "GET", "urlPath": "/scenario"},
+      "response": {"status": 200}
+    }
+  ]
+}`)
+	writeFile(t, root, "mappings/post_serve.json", `{
+  "postServeActions": {"webhook": {}},
+  "request": {"method": "GET", "urlPath": "/webhook"},
+  "response": {"status": 200}
+}`)
+	writeFile(t, root, "__files/verbose.json", `{"name": "verbose-user"}`)
+
+	h, unsupported, err := LoadWireMock(root)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if len(unsupported) != 5 {
+		t.Fatalf("want 5 unsupported features, but got %v", unsupported)
+	}
+
+	cases := []struct {
+		method     string
+		target     string
+		auth       string
+		body       string
+		wantCode   int
+		wantBody   string
+		wantHeader string
+	}{
+		{method: "GET", target: "/users/1", auth: "Bearer x", wantCode: 200, wantBody: `{"name":"test-user"}`, wantHeader: "user"},
+		{method: "GET", target: "/users/1", wantCode: 404},
+		{method: "GET", target: "/users/a", auth: "Bearer x", wantCode: 404},
+		{method: "GET", target: "/users/1?verbose=true", auth: "Bearer x", wantCode: 200, wantBody: `{"name":"verbose-user"}`},
+		{method: "POST", target: "/users", body: `{"name":"new-user"}`, wantCode: 201, wantBody: `{"id":2}`},
+		{method: "POST", target: "/users", body: `{"name":"other"}`, wantCode: 404},
+		{method: "DELETE", target: "/any", wantCode: 204},
+		{method: "GET", target: "/xml", wantCode: 404},
+		{method: "GET", target: "/webhook", wantCode: 404},
+		{method: "GET", target: "/operators", wantCode: 404},
+		{method: "GET", target: "/case?q=A", wantCode: 404},
+		{method: "GET", target: "/scenario", wantCode: 202},
+		{method: "GET", target: "/scenario", wantCode: 200},
+		{method: "GET", target: "/scenario", wantCode: 200},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.method+tt.target, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://localhost"+tt.target, strings.NewReader(tt.body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			res := w.Result()
+			if res.StatusCode != tt.wantCode {
+				t.Fatalf("want %v, but got %v", tt.wantCode, res.StatusCode)
+			}
+			if tt.wantBody == "" {
+				return
+			}
+			b, _ := ioutil.ReadAll(res.Body)
+			if got := strings.TrimSpace(string(b)); got != tt.wantBody {
+				t.Fatalf("want %v, but got %v", tt.wantBody, got)
+			}
+			if got := res.Header.Get("X-Test"); got != tt.wantHeader {
+				t.Fatalf("want %v, but got %v", tt.wantHeader, got)
+			}
+		})
+	}
+}
+
+func TestLoadWireMock_fault(t *testing.T) {
+	root, err := ioutil.TempDir("", "fakehttp")
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	defer os.RemoveAll(root)
+	writeFile(t, root, "mappings/reset.json", `{
+      "request": {"method": "GET", "url": "/reset"},
+      "response": {"fault": "CONNECTION_RESET_BY_PEER"}
+    }`)
+	writeFile(t, root, "mappings/random.json", `{
+      "request": {"method": "GET", "url": "/random"},
+      "response": {"status": 200, "fault": "RANDOM_DATA_THEN_CLOSE"}
+    }`)
+
+	h, unsupported, err := LoadWireMock(root)
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	if len(unsupported) != 1 {
+		t.Fatalf("want 1 unsupported feature, but got %v", unsupported)
+	}
+	s := httptest.NewServer(h)
+	defer s.Close()
+
+	if res, err := http.Get(s.URL + "/reset"); err == nil {
+		res.Body.Close()
+		t.Fatalf("should be error, but not")
+	}
+	res, err := http.Get(s.URL + "/random")
+	if err != nil {
+		t.Fatalf("should not be error, but: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != 200 {
+		t.Fatalf("want 200, but got %v", res.StatusCode)
+	}
+}